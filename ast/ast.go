@@ -0,0 +1,251 @@
+// Package ast declares the types used to represent gcfg documents as trees of
+// sections, subsections and variables, and implements parsing gcfg data into
+// such trees and printing them.
+//
+// The tree can be inspected and modified without a Go type describing the
+// configuration; gcfg.ASTSource decodes it into a config struct.
+//
+// Section and variable names are matched ignoring case; subsection names are
+// case sensitive. A section or variable may be defined more than once; as when
+// reading into a struct, the last definition of a single-valued variable is the
+// one in effect.
+package ast
+
+import (
+	"strings"
+)
+
+import (
+	"github.com/baobabus/gcfg/token"
+)
+
+// A Comment is a comment line, or the comment ending a line.
+type Comment struct {
+	Pos  token.Pos // position of the ';' or '#'
+	Text string    // comment text, including the leading ';' or '#'
+}
+
+// A Variable is a variable definition.
+type Variable struct {
+	Doc      []*Comment // comment lines before the definition
+	Pos      token.Pos  // position of the name
+	Name     string
+	Blank    bool      // defined without equals sign and value
+	ValuePos token.Pos // position of the value; NoPos if blank
+	Value    string    // unquoted value; empty if blank
+	Raw      string    // value as written; empty if blank or set programmatically
+	Comment  *Comment  // comment ending the line; or nil
+	End      token.Pos // position of the line break or EOF ending the line
+}
+
+// SetValue sets the value of v.
+func (v *Variable) SetValue(value string) {
+	v.Blank, v.Value, v.Raw = false, value, ""
+}
+
+// SetBlank makes v a definition without equals sign and value.
+func (v *Variable) SetBlank() {
+	v.Blank, v.Value, v.Raw = true, "", ""
+}
+
+// A Section is a section header together with the variable definitions
+// following it.
+type Section struct {
+	Doc        []*Comment // comment lines before the header
+	Pos        token.Pos  // position of the '['
+	Name       string
+	Subsection string    // subsection name; empty if none
+	Rbrack     token.Pos // position of the ']'
+	Comment    *Comment  // comment ending the header line; or nil
+	End        token.Pos // position of the line break or EOF ending the header line
+	Vars       []*Variable
+}
+
+// Is reports whether s is a header for section name and subsection sub.
+func (s *Section) Is(name, sub string) bool {
+	return strings.EqualFold(s.Name, name) && s.Subsection == sub
+}
+
+// Lookup returns the definitions of the variable name in s, in order.
+func (s *Section) Lookup(name string) []*Variable {
+	var vars []*Variable
+	for _, v := range s.Vars {
+		if strings.EqualFold(v.Name, name) {
+			vars = append(vars, v)
+		}
+	}
+	return vars
+}
+
+// Get returns the last definition of the variable name in s, or nil if there
+// is none.
+func (s *Section) Get(name string) *Variable {
+	if vars := s.Lookup(name); len(vars) > 0 {
+		return vars[len(vars)-1]
+	}
+	return nil
+}
+
+// Add appends a definition of the variable name with value to s.
+func (s *Section) Add(name, value string) *Variable {
+	v := &Variable{Name: name, Value: value}
+	s.Vars = append(s.Vars, v)
+	return v
+}
+
+// AddBlank appends a definition of the variable name without value to s.
+func (s *Section) AddBlank(name string) *Variable {
+	v := &Variable{Name: name, Blank: true}
+	s.Vars = append(s.Vars, v)
+	return v
+}
+
+// Set sets the value of the last definition of the variable name in s and
+// removes the others, or appends a definition if there is none.
+func (s *Section) Set(name, value string) *Variable {
+	v := s.Get(name)
+	if v == nil {
+		return s.Add(name, value)
+	}
+	s.remove(name, v)
+	v.SetValue(value)
+	return v
+}
+
+// Unset removes the definitions of the variable name from s, and returns the
+// number of definitions removed.
+func (s *Section) Unset(name string) int {
+	n := len(s.Vars)
+	s.remove(name, nil)
+	return n - len(s.Vars)
+}
+
+// remove removes the definitions of the variable name other than keep.
+func (s *Section) remove(name string, keep *Variable) {
+	vars := s.Vars[:0]
+	for _, v := range s.Vars {
+		if v == keep || !strings.EqualFold(v.Name, name) {
+			vars = append(vars, v)
+		}
+	}
+	for i := len(vars); i < len(s.Vars); i++ {
+		s.Vars[i] = nil
+	}
+	s.Vars = vars
+}
+
+// A File is a parsed gcfg document.
+type File struct {
+	Name     string // file name as passed to Parse
+	Sections []*Section
+	Trailing []*Comment // comment lines after the last definition
+}
+
+// Section returns the first header for section name and subsection sub, or nil
+// if there is none.
+func (f *File) Section(name, sub string) *Section {
+	for _, s := range f.Sections {
+		if s.Is(name, sub) {
+			return s
+		}
+	}
+	return nil
+}
+
+// Subsections returns the names of the subsections of section name, in order
+// of first appearance. The empty name stands for the section without
+// subsection.
+func (f *File) Subsections(name string) []string {
+	var subs []string
+	seen := map[string]bool{}
+	for _, s := range f.Sections {
+		if strings.EqualFold(s.Name, name) && !seen[s.Subsection] {
+			seen[s.Subsection] = true
+			subs = append(subs, s.Subsection)
+		}
+	}
+	return subs
+}
+
+// Lookup returns the definitions of the variable name in all headers for
+// section sect and subsection sub, in order.
+func (f *File) Lookup(sect, sub, name string) []*Variable {
+	var vars []*Variable
+	for _, s := range f.Sections {
+		if s.Is(sect, sub) {
+			vars = append(vars, s.Lookup(name)...)
+		}
+	}
+	return vars
+}
+
+// Get returns the last definition of the variable name in section sect and
+// subsection sub, or nil if there is none.
+func (f *File) Get(sect, sub, name string) *Variable {
+	if vars := f.Lookup(sect, sub, name); len(vars) > 0 {
+		return vars[len(vars)-1]
+	}
+	return nil
+}
+
+// AddSection appends a header for section name and subsection sub to f.
+func (f *File) AddSection(name, sub string) *Section {
+	s := &Section{Name: name, Subsection: sub}
+	f.Sections = append(f.Sections, s)
+	return s
+}
+
+// Set sets the value of the last definition of the variable name in section
+// sect and subsection sub, and removes the others. If there is none, the
+// definition is appended to the last header for the section, which is added
+// if needed.
+func (f *File) Set(sect, sub, name, value string) *Variable {
+	v := f.Get(sect, sub, name)
+	var last *Section
+	for _, s := range f.Sections {
+		if !s.Is(sect, sub) {
+			continue
+		}
+		last = s
+		if v != nil {
+			s.remove(name, v)
+		}
+	}
+	if v != nil {
+		v.SetValue(value)
+		return v
+	}
+	if last == nil {
+		last = f.AddSection(sect, sub)
+	}
+	return last.Add(name, value)
+}
+
+// Unset removes the definitions of the variable name in section sect and
+// subsection sub, and returns the number of definitions removed.
+func (f *File) Unset(sect, sub, name string) int {
+	n := 0
+	for _, s := range f.Sections {
+		if s.Is(sect, sub) {
+			n += s.Unset(name)
+		}
+	}
+	return n
+}
+
+// RemoveSection removes the headers for section name and subsection sub,
+// together with their variables, and returns the number of headers removed.
+func (f *File) RemoveSection(name, sub string) int {
+	sects := f.Sections[:0]
+	for _, s := range f.Sections {
+		if !s.Is(name, sub) {
+			sects = append(sects, s)
+		}
+	}
+	n := len(f.Sections) - len(sects)
+	for i := len(sects); i < len(f.Sections); i++ {
+		f.Sections[i] = nil
+	}
+	f.Sections = sects
+	return n
+}
@@ -0,0 +1,83 @@
+package ast
+
+import (
+	"reflect"
+	"testing"
+)
+
+import (
+	"github.com/baobabus/gcfg/token"
+)
+
+func mustParse(t *testing.T, src string) *File {
+	f, err := Parse(token.NewFileSet(), "", []byte(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return f
+}
+
+func values(vars []*Variable) []string {
+	vals := []string{}
+	for _, v := range vars {
+		vals = append(vals, v.Value)
+	}
+	return vals
+}
+
+func TestQuery(t *testing.T) {
+	f := mustParse(t, "[a]\nx=1\n[A \"s\"]\nx=2\n[a]\nX=3\ny\n[a \"S\"]\n[b]\nx=4")
+	for i, tt := range []struct {
+		sect, sub, name string
+		vals            []string
+	}{
+		{"a", "", "x", []string{"1", "3"}},
+		{"A", "", "X", []string{"1", "3"}},
+		{"a", "s", "x", []string{"2"}},
+		{"a", "S", "x", []string{}},
+		{"a", "", "y", []string{""}},
+		{"c", "", "x", []string{}},
+	} {
+		if vals := values(f.Lookup(tt.sect, tt.sub, tt.name)); !reflect.DeepEqual(vals, tt.vals) {
+			t.Errorf("%d: got %q, wanted %q", i, vals, tt.vals)
+		}
+	}
+	if v := f.Get("a", "", "x"); v == nil || v.Value != "3" {
+		t.Errorf("got %#v, wanted last definition", v)
+	}
+	if subs := f.Subsections("a"); !reflect.DeepEqual(subs, []string{"", "s", "S"}) {
+		t.Errorf("got subsections %q", subs)
+	}
+	if s := f.Section("b", ""); s == nil || s.Get("x").Value != "4" {
+		t.Errorf("got section %#v", s)
+	}
+}
+
+func TestMutate(t *testing.T) {
+	f := mustParse(t, "[a]\nx=1\nm=1\n[b]\n[a]\nx=2\nm=2")
+	if v := f.Set("a", "", "x", "3"); !v.Pos.IsValid() {
+		t.Errorf("got new definition, wanted last one updated")
+	}
+	if vals := values(f.Lookup("a", "", "x")); !reflect.DeepEqual(vals, []string{"3"}) {
+		t.Errorf("got %q after Set", vals)
+	}
+	f.Set("a", "sub", "x", "4")
+	f.Set("b", "", "y", "5")
+	if n := f.Unset("a", "", "m"); n != 2 {
+		t.Errorf("got %d removed, wanted 2", n)
+	}
+	f.Section("a", "").AddBlank("flag")
+	if n := f.RemoveSection("b", ""); n != 1 {
+		t.Errorf("got %d removed, wanted 1", n)
+	}
+	got := []string{}
+	for _, s := range f.Sections {
+		for _, v := range s.Vars {
+			got = append(got, s.Name+"."+s.Subsection+"."+v.Name+"="+v.Value)
+		}
+	}
+	exp := []string{"a..flag=", "a..x=3", "a.sub.x=4"}
+	if !reflect.DeepEqual(got, exp) {
+		t.Errorf("got %q, wanted %q", got, exp)
+	}
+}
@@ -0,0 +1,186 @@
+package ast
+
+import (
+	"github.com/baobabus/gcfg/scanner"
+	"github.com/baobabus/gcfg/token"
+)
+
+var unescape = map[rune]rune{'\\': '\\', '"': '"', 'n': '\n', 't': '\t'}
+
+// unquote returns the value of the literal s as scanned; invalid literals are
+// reported by the scanner and never unquoted.
+func unquote(s string) string {
+	u, q, esc := make([]rune, 0, len(s)), false, false
+	for _, c := range s {
+		if esc {
+			uc, ok := unescape[c]
+			switch {
+			case ok:
+				u = append(u, uc)
+				fallthrough
+			case !q && c == '\n':
+				esc = false
+				continue
+			}
+			panic("invalid escape sequence")
+		}
+		switch c {
+		case '"':
+			q = !q
+		case '\\':
+			esc = true
+		default:
+			u = append(u, c)
+		}
+	}
+	if q {
+		panic("missing end quote")
+	}
+	if esc {
+		panic("invalid escape sequence")
+	}
+	return string(u)
+}
+
+// Parse parses the gcfg data src and returns the document tree. A file named
+// filename is added to fset for position information.
+//
+// Syntax errors are returned as a scanner.ErrorList, sorted by position and
+// with at most one error per line. The tree then holds the definitions on the
+// valid lines, except for the variables following an invalid section header.
+func Parse(fset *token.FileSet, filename string, src []byte) (*File, error) {
+	file := fset.AddFile(filename, fset.Base(), len(src))
+	var errs scanner.ErrorList
+	var s scanner.Scanner
+	s.Init(file, src, errs.Add, scanner.ScanComments)
+	f := &File{Name: filename}
+	var sect *Section
+	// set if the current section header is invalid
+	badsect := false
+	// comment lines not yet attached to a definition
+	var doc []*Comment
+	pos, tok, lit := s.Scan()
+	errfn := func(msg string) {
+		errs.Add(fset.Position(pos), msg)
+	}
+	// scan advances to the next token and reports whether it was scanned
+	// without errors
+	scan := func() bool {
+		n := s.ErrorCount
+		pos, tok, lit = s.Scan()
+		return s.ErrorCount == n
+	}
+	// endLine returns the comment ending the line, if any, and the position
+	// of the line end; it reports an error unless the line ends there
+	endLine := func() (*Comment, token.Pos) {
+		var c *Comment
+		if tok == token.COMMENT {
+			c = &Comment{Pos: pos, Text: lit}
+			scan()
+		}
+		if tok != token.EOL && tok != token.EOF {
+			errfn("expected EOL, EOF, or comment")
+		}
+		return c, pos
+	}
+	for {
+		nerrs := errs.Len()
+		switch tok {
+		case token.EOF:
+			f.Trailing = doc
+			errs.Sort()
+			errs.RemoveMultiples()
+			return f, errs.Err()
+		case token.EOL:
+			scan()
+		case token.COMMENT:
+			doc = append(doc, &Comment{Pos: pos, Text: lit})
+			scan()
+		case token.LBRACK:
+			sect, badsect = nil, true
+			hpos := pos
+			if !scan() {
+				break
+			}
+			if tok != token.IDENT {
+				errfn("expected section name")
+				break
+			}
+			name := lit
+			if !scan() {
+				break
+			}
+			sub := ""
+			if tok == token.STRING {
+				sub = unquote(lit)
+				if sub == "" {
+					errfn("empty subsection name")
+					break
+				}
+				if !scan() {
+					break
+				}
+			}
+			if tok != token.RBRACK {
+				if sub == "" {
+					errfn("expected subsection name or right bracket")
+				} else {
+					errfn("expected right bracket")
+				}
+				break
+			}
+			sect = &Section{Doc: doc, Pos: hpos, Name: name, Subsection: sub, Rbrack: pos}
+			f.Sections = append(f.Sections, sect)
+			doc, badsect = nil, false
+			scan()
+			sect.Comment, sect.End = endLine()
+		case token.IDENT:
+			if sect == nil && !badsect {
+				errfn("expected section header")
+				break
+			}
+			v := &Variable{Pos: pos, Name: lit}
+			if !scan() {
+				break
+			}
+			v.Blank = tok == token.EOF || tok == token.EOL || tok == token.COMMENT
+			if !v.Blank {
+				if tok != token.ASSIGN {
+					errfn("expected '='")
+					break
+				}
+				if !scan() {
+					break
+				}
+				if tok != token.STRING {
+					errfn("expected value")
+					break
+				}
+				v.ValuePos, v.Value, v.Raw = pos, unquote(lit), lit
+				if !scan() {
+					break
+				}
+			}
+			v.Comment, v.End = endLine()
+			if errs.Len() > nerrs || badsect {
+				break
+			}
+			v.Doc, doc = doc, nil
+			sect.Vars = append(sect.Vars, v)
+		default:
+			if sect == nil && !badsect {
+				errfn("expected section header")
+			} else {
+				errfn("expected section header or variable declaration")
+			}
+		}
+		if errs.Len() > nerrs {
+			// resume at the next line; the scanner may already have
+			// consumed the line break of an unterminated string
+			line := file.Line(pos)
+			for tok != token.EOL && tok != token.EOF && file.Line(pos) == line {
+				scan()
+			}
+		}
+	}
+}
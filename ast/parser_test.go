@@ -0,0 +1,93 @@
+package ast
+
+import (
+	"reflect"
+	"testing"
+)
+
+import (
+	"github.com/baobabus/gcfg/scanner"
+	"github.com/baobabus/gcfg/token"
+)
+
+const parseSrc = `; file doc
+# more
+
+[section] ; header
+name = value ; line
+; var doc
+blank
+quoted = " a;b "
+cont = a \
+b
+
+[sub "A"]
+x=1
+; trailing
+`
+
+func TestParse(t *testing.T) {
+	fset := token.NewFileSet()
+	f, err := Parse(fset, "test.gcfg", []byte(parseSrc))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(f.Sections) != 2 {
+		t.Fatalf("got %d sections, wanted 2", len(f.Sections))
+	}
+	s := f.Sections[0]
+	if s.Name != "section" || s.Subsection != "" || len(s.Doc) != 2 ||
+		s.Doc[1].Text != "# more" || s.Comment == nil || s.Comment.Text != "; header" {
+		t.Errorf("got section %#v", s)
+	}
+	if p := fset.Position(s.Pos); p.Line != 4 || p.Column != 1 {
+		t.Errorf("got section position %v, wanted 4:1", p)
+	}
+	exp := []Variable{
+		{Name: "name", Value: "value", Raw: "value"},
+		{Name: "blank", Blank: true},
+		{Name: "quoted", Value: " a;b ", Raw: `" a;b "`},
+		{Name: "cont", Value: "a b", Raw: "a \\\nb"},
+	}
+	if len(s.Vars) != len(exp) {
+		t.Fatalf("got %d variables, wanted %d", len(s.Vars), len(exp))
+	}
+	for i, v := range s.Vars {
+		got := Variable{Name: v.Name, Blank: v.Blank, Value: v.Value, Raw: v.Raw}
+		if !reflect.DeepEqual(got, exp[i]) {
+			t.Errorf("%d: got %+v, wanted %+v", i, got, exp[i])
+		}
+	}
+	if c := s.Vars[0].Comment; c == nil || c.Text != "; line" {
+		t.Errorf("got line comment %#v", c)
+	}
+	if d := s.Vars[1].Doc; len(d) != 1 || d[0].Text != "; var doc" {
+		t.Errorf("got variable doc %#v", d)
+	}
+	if s := f.Sections[1]; s.Name != "sub" || s.Subsection != "A" || len(s.Vars) != 1 {
+		t.Errorf("got section %#v", s)
+	}
+	if len(f.Trailing) != 1 || f.Trailing[0].Text != "; trailing" {
+		t.Errorf("got trailing comments %#v", f.Trailing)
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	src := "x=1\n[section]\na=1\nb c\n[bad\nd=1\n[ok]\ne=\"2\n"
+	f, err := Parse(token.NewFileSet(), "", []byte(src))
+	el, ok := err.(scanner.ErrorList)
+	if !ok {
+		t.Fatalf("got error %#v, wanted scanner.ErrorList", err)
+	}
+	var lines []int
+	for _, e := range el {
+		lines = append(lines, e.Pos.Line)
+	}
+	if exp := []int{1, 4, 5, 8}; !reflect.DeepEqual(lines, exp) {
+		t.Errorf("got errors on lines %v, wanted %v: %v", lines, exp, err)
+	}
+	// valid lines are kept, except for variables after an invalid header
+	if len(f.Sections) != 2 || len(f.Sections[0].Vars) != 1 || len(f.Sections[1].Vars) != 0 {
+		t.Errorf("got sections %#v", f.Sections)
+	}
+}
@@ -0,0 +1,153 @@
+package ast
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// IsName reports whether s is a valid section or variable name: a letter
+// followed by letters, digits and hyphens.
+func IsName(s string) bool {
+	for i, r := range s {
+		if !unicode.IsLetter(r) && (i == 0 || !unicode.IsDigit(r) && r != '-') {
+			return false
+		}
+	}
+	return s != ""
+}
+
+// QuoteValue returns value as written in gcfg data, quoted and escaped as
+// needed to read back unchanged. An error is returned if value is not valid
+// UTF-8 or contains a character that cannot be represented (carriage return
+// or NUL).
+func QuoteValue(value string) (string, error) {
+	if !utf8.ValidString(value) {
+		return "", fmt.Errorf("value %q: invalid UTF-8", value)
+	}
+	if i := strings.IndexAny(value, "\r\x00"); i >= 0 {
+		return "", fmt.Errorf("value %q: character %q cannot be represented", value, value[i])
+	}
+	if value != "" && value == strings.Trim(value, " \t") &&
+		!strings.ContainsAny(value, ";#\"\\\n\t") {
+		return value, nil
+	}
+	var b bytes.Buffer
+	b.WriteByte('"')
+	for _, r := range value {
+		switch r {
+		case '\\', '"':
+			b.WriteByte('\\')
+			b.WriteRune(r)
+		case '\n':
+			b.WriteString(`\n`)
+		case '\t':
+			b.WriteString(`\t`)
+		default:
+			b.WriteRune(r)
+		}
+	}
+	b.WriteByte('"')
+	return b.String(), nil
+}
+
+// QuoteSubsection returns the subsection name sub quoted and escaped as in a
+// section header. An error is returned if sub is empty, is not valid UTF-8 or
+// contains a character that cannot be represented (line break or NUL).
+func QuoteSubsection(sub string) (string, error) {
+	if sub == "" {
+		return "", fmt.Errorf("empty subsection name")
+	}
+	if !utf8.ValidString(sub) {
+		return "", fmt.Errorf("subsection %q: invalid UTF-8", sub)
+	}
+	if i := strings.IndexAny(sub, "\n\r\x00"); i >= 0 {
+		return "", fmt.Errorf("subsection %q: character %q cannot be represented", sub, sub[i])
+	}
+	r := strings.NewReplacer(`\`, `\\`, `"`, `\"`)
+	return `"` + r.Replace(sub) + `"`, nil
+}
+
+// Header returns the section header for section name and subsection sub, as
+// written in gcfg data.
+func Header(name, sub string) (string, error) {
+	if !IsName(name) {
+		return "", fmt.Errorf("invalid section name %q", name)
+	}
+	if sub == "" {
+		return "[" + name + "]", nil
+	}
+	q, err := QuoteSubsection(sub)
+	if err != nil {
+		return "", err
+	}
+	return "[" + name + " " + q + "]", nil
+}
+
+type printer struct {
+	w   io.Writer
+	err error
+}
+
+func (p *printer) print(a ...string) {
+	for _, s := range a {
+		if p.err == nil {
+			_, p.err = io.WriteString(p.w, s)
+		}
+	}
+}
+
+func (p *printer) comments(cs []*Comment) {
+	for _, c := range cs {
+		p.print(c.Text, "\n")
+	}
+}
+
+func (p *printer) endLine(c *Comment) {
+	if c != nil {
+		p.print(" ", c.Text)
+	}
+	p.print("\n")
+}
+
+// Fprint writes f to w as gcfg data. Values are written as originally read, or
+// if set programmatically, quoted as needed. Comment lines are written before
+// the definitions they are attached to, and sections are separated by empty
+// lines; other layout is not preserved.
+func Fprint(w io.Writer, f *File) error {
+	p := &printer{w: w}
+	for i, s := range f.Sections {
+		h, err := Header(s.Name, s.Subsection)
+		if err != nil {
+			return err
+		}
+		if i > 0 {
+			p.print("\n")
+		}
+		p.comments(s.Doc)
+		p.print(h)
+		p.endLine(s.Comment)
+		for _, v := range s.Vars {
+			if !IsName(v.Name) {
+				return fmt.Errorf("invalid variable name %q", v.Name)
+			}
+			p.comments(v.Doc)
+			p.print(v.Name)
+			if !v.Blank {
+				val := v.Raw
+				if val == "" {
+					if val, err = QuoteValue(v.Value); err != nil {
+						return err
+					}
+				}
+				p.print(" = ", val)
+			}
+			p.endLine(v.Comment)
+		}
+	}
+	p.comments(f.Trailing)
+	return p.err
+}
@@ -0,0 +1,48 @@
+package ast
+
+import (
+	"bytes"
+	"testing"
+)
+
+import (
+	"github.com/baobabus/gcfg/token"
+)
+
+func TestFprint(t *testing.T) {
+	f := mustParse(t, "; doc\n[a] ; c\nx = \"q\" ; c2\n\n\n[b \"s\\\"\"]\n  y\n; end\n")
+	f.Set("a", "", "z", " spaced ")
+	f.Set("a", "", "w", "tab\tand \\ and \"")
+	f.AddSection("c", "d").Add("e", "")
+	var buf bytes.Buffer
+	if err := Fprint(&buf, f); err != nil {
+		t.Fatal(err)
+	}
+	exp := "; doc\n[a] ; c\nx = \"q\" ; c2\nz = \" spaced \"\n" +
+		"w = \"tab\\tand \\\\ and \\\"\"\n\n[b \"s\\\"\"]\ny\n\n[c \"d\"]\ne = \"\"\n; end\n"
+	if buf.String() != exp {
+		t.Errorf("got\n%s\nwanted\n%s", buf.String(), exp)
+	}
+	g, err := Parse(token.NewFileSet(), "", buf.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, n := range []string{"x", "z", "w"} {
+		if g.Get("a", "", n).Value != f.Get("a", "", n).Value {
+			t.Errorf("%s: got %q, wanted %q", n, g.Get("a", "", n).Value, f.Get("a", "", n).Value)
+		}
+	}
+}
+
+func TestFprintInvalid(t *testing.T) {
+	for i, f := range []*File{
+		{Sections: []*Section{{Name: "1a"}}},
+		{Sections: []*Section{{Name: "a", Subsection: "x\ny"}}},
+		{Sections: []*Section{{Name: "a", Vars: []*Variable{{Name: "b", Value: "\r"}}}}},
+		{Sections: []*Section{{Name: "a", Vars: []*Variable{{Name: "b c", Blank: true}}}}},
+	} {
+		if err := Fprint(&bytes.Buffer{}, f); err == nil {
+			t.Errorf("%d: got ok, wanted error", i)
+		}
+	}
+}
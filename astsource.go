@@ -0,0 +1,23 @@
+package gcfg
+
+import (
+	"github.com/baobabus/gcfg/ast"
+	"github.com/baobabus/gcfg/token"
+)
+
+type astSource struct {
+	fset *token.FileSet
+	file *ast.File
+}
+
+// ASTSource returns a Source setting the variables defined in the document
+// tree f, as if reading the gcfg data it represents. Positions in errors are
+// resolved using fset, the file set f was parsed with; definitions added to
+// the tree after parsing have no position.
+func ASTSource(fset *token.FileSet, f *ast.File) Source {
+	return astSource{fset, f}
+}
+
+func (s astSource) readInto(rs *readState, config interface{}) error {
+	return rs.readTree(config, s.fset, s.file, nil)
+}
@@ -0,0 +1,39 @@
+package gcfg
+
+import (
+	"testing"
+)
+
+import (
+	"github.com/baobabus/gcfg/ast"
+	"github.com/baobabus/gcfg/token"
+)
+
+func TestASTSource(t *testing.T) {
+	fset := token.NewFileSet()
+	f, err := ast.Parse(fset, "test.gcfg", []byte("[section]\nname=value\n[sub \"a\"]\nint=x"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// errors are positioned in the parsed file
+	err = ReadSourcesInto(&cSources{}, []Source{ASTSource(fset, f)})
+	if e, ok := err.(*Error); !ok || e.Kind != ParseError || e.Pos.String() != "test.gcfg:4:1" {
+		t.Errorf("got error %#v, wanted parse error at test.gcfg:4:1", err)
+	}
+	f.Get("sub", "a", "int").SetValue("1")
+	f.Set("section", "", "multi", "m")
+	res := &cSources{}
+	if err := ReadSourcesInto(res, []Source{ASTSource(fset, f)}); err != nil {
+		t.Fatal(err)
+	}
+	if res.Section.Name != "value" || len(res.Section.Multi) != 1 ||
+		res.Sub["a"] == nil || res.Sub["a"].Int != 1 {
+		t.Errorf("got %#v", res)
+	}
+	// definitions added after parsing have no position
+	f.Sections[0].Add("unknown", "1")
+	err = ReadSourcesInto(&cSources{}, []Source{ASTSource(fset, f)})
+	if e, ok := err.(*Error); !ok || e.Kind != UnknownVariable || e.Pos.IsValid() {
+		t.Errorf("got error %#v, wanted unknown variable without position", err)
+	}
+}
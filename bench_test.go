@@ -0,0 +1,61 @@
+package gcfg
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+)
+
+type cBench struct {
+	Global struct {
+		Name    string
+		Verbose bool
+	}
+	Server map[string]*cBenchServer
+}
+type cBenchServer struct {
+	cBenchCommon
+	Host       string
+	Port       int    `min:"1" max:"65535"`
+	Path       string `gcfg:"base-path"`
+	Alias      []string
+	Timeout    int
+	Retries    int
+	Weight     uint8
+	Enabled    bool
+	Max_Conns  int
+	Keep_Alive bool
+}
+type cBenchCommon struct {
+	Comment string
+	Owner   string
+}
+
+// benchConfig returns generated gcfg data with n subsections.
+func benchConfig(n int) []byte {
+	var b bytes.Buffer
+	b.WriteString("[global]\nname=bench\nverbose\n")
+	for i := 0; i < n; i++ {
+		fmt.Fprintf(&b, "\n[server \"s%d\"]\n", i)
+		fmt.Fprintf(&b, "host=host%d.example.com\nport=%d\nbase-path=/srv/%d\n", i, 1000+i, i)
+		fmt.Fprintf(&b, "alias=a%d\nalias=b%d\ntimeout=30\nretries=3\nweight=%d\n", i, i, i%256)
+		fmt.Fprintf(&b, "enabled=true\nmax-conns=100\nkeep-alive\ncomment=server %d\nowner=ops\n", i)
+	}
+	return b.Bytes()
+}
+
+func benchmarkRead(b *testing.B, n int) {
+	src := benchConfig(n)
+	b.SetBytes(int64(len(src)))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		var cfg cBench
+		if err := ReadInto(&cfg, bytes.NewReader(src)); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkRead10(b *testing.B)   { benchmarkRead(b, 10) }
+func BenchmarkRead100(b *testing.B)  { benchmarkRead(b, 100) }
+func BenchmarkRead1000(b *testing.B) { benchmarkRead(b, 1000) }
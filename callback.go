@@ -0,0 +1,67 @@
+package gcfg
+
+import (
+	"fmt"
+	"reflect"
+)
+
+import (
+	"github.com/baobabus/gcfg/token"
+)
+
+// A Definition describes a variable definition read, as passed to the
+// callbacks given with the "cb" struct tag option. Section, Subsection and
+// Variable are spelled as in the input, and Value holds the raw (unquoted)
+// value. Pos is the position of the definition, if read from gcfg data.
+type Definition struct {
+	Pos        token.Position
+	Section    string
+	Subsection string
+	Variable   string
+	Value      string
+	Blank      bool
+}
+
+var definitionType = reflect.TypeOf(Definition{})
+var errorType = reflect.TypeOf((*error)(nil)).Elem()
+
+// checkCallback returns an error unless pointers to the struct type t have a
+// method name usable as a callback: one with no arguments or a Definition
+// argument, and no result or an error result.
+func checkCallback(t reflect.Type, name string) error {
+	m, ok := reflect.PtrTo(t).MethodByName(name)
+	if !ok {
+		return fmt.Errorf("no callback method %s on *%v", name, t)
+	}
+	// the receiver is the first argument
+	mt := m.Type
+	if mt.NumIn() > 2 || mt.NumIn() == 2 && mt.In(1) != definitionType ||
+		mt.NumOut() > 1 || mt.NumOut() == 1 && mt.Out(0) != errorType {
+		return fmt.Errorf("callback method %s has type %v, want no arguments "+
+			"or a gcfg.Definition and no result or an error", name, mt)
+	}
+	return nil
+}
+
+// callback calls the callback method name on vs, the struct declaring the
+// variable set or a pointer to it, with the definition d. It returns the
+// error returned by the method, if any.
+func callback(vs reflect.Value, name string, d Definition) error {
+	if vs.Kind() != reflect.Ptr {
+		vs = vs.Addr()
+	}
+	m := vs.MethodByName(name)
+	var in []reflect.Value
+	if m.Type().NumIn() == 1 {
+		in = append(in, reflect.ValueOf(d))
+	}
+	out := m.Call(in)
+	if len(out) == 0 || out[0].IsNil() {
+		return nil
+	}
+	err := out[0].Interface().(error)
+	if _, ok := err.(*Error); !ok {
+		err = &Error{Kind: CallbackError, Err: err}
+	}
+	return err
+}
@@ -0,0 +1,97 @@
+package gcfg
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+type cCallbackSect struct {
+	Name  string   `gcfg:",cb=Record"`
+	Multi []string `gcfg:",cb=Record"`
+	Port  int      `gcfg:",cb=CheckPort"`
+	defs  []Definition
+}
+
+func (s *cCallbackSect) Record(d Definition) { s.defs = append(s.defs, d) }
+
+func (s *cCallbackSect) CheckPort() error {
+	switch {
+	case s.Port == 0:
+		return &Error{Kind: BoundsError, Err: fmt.Errorf("port 0")}
+	case s.Port < 0:
+		return fmt.Errorf("negative port")
+	}
+	return nil
+}
+
+type cCallback struct {
+	Sub map[string]*cCallbackSect
+}
+
+type cCallbackMissing struct {
+	Section struct {
+		Name string `gcfg:",cb=Missing"`
+	}
+}
+
+type cCallbackMismatched struct {
+	Section cCallbackMismatchedSect
+}
+
+type cCallbackMismatchedSect struct {
+	Name string `gcfg:",cb=Cb"`
+}
+
+func (s *cCallbackMismatchedSect) Cb(name string) bool { return true }
+
+func TestCallbackDefinition(t *testing.T) {
+	cfg := &cCallback{}
+	err := ReadStringInto(cfg, "[sub \"A\"]\nName = x\nmulti\nmulti=y", SourceName("f"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defs := cfg.Sub["A"].defs
+	// blank values of multi-valued variables do not trigger callbacks
+	if len(defs) != 2 {
+		t.Fatalf("got %d definitions, want 2", len(defs))
+	}
+	if d := defs[0]; d.Pos.String() != "f:2:1" || d.Section != "sub" ||
+		d.Subsection != "A" || d.Variable != "Name" || d.Value != "x" || d.Blank {
+		t.Errorf("got %+v", d)
+	}
+	if d := defs[1]; d.Pos.String() != "f:4:1" || d.Variable != "multi" || d.Value != "y" {
+		t.Errorf("got %+v", d)
+	}
+}
+
+func TestCallbackErrors(t *testing.T) {
+	for i, tt := range []struct {
+		cfg  interface{}
+		in   string
+		kind ErrorKind
+		err  string
+	}{
+		{&cCallback{}, "[sub \"a\"]\nport=-1", CallbackError,
+			"2:1: negative port: section \"sub\" subsection \"a\" variable \"port\""},
+		{&cCallback{}, "[sub \"a\"]\nport=0", BoundsError, "2:1: port 0"},
+		{&cCallbackMissing{}, "", TypeError, "no callback method Missing"},
+		{&cCallbackMismatched{}, "", TypeError, "callback method Cb has type"},
+	} {
+		err := ReadStringInto(tt.cfg, tt.in)
+		e, ok := err.(*Error)
+		switch {
+		case !ok:
+			t.Errorf("%d: got error %v, want *Error", i, err)
+		case e.Kind != tt.kind:
+			t.Errorf("%d: got kind %v, want %v", i, e.Kind, tt.kind)
+		case !strings.Contains(e.Error(), tt.err):
+			t.Errorf("%d: got error %q, want %q", i, e.Error(), tt.err)
+		}
+	}
+	var buf bytes.Buffer
+	if err := Write(&cCallbackMissing{}, &buf); err == nil {
+		t.Errorf("no error writing config with missing callback method")
+	}
+}
@@ -0,0 +1,46 @@
+package gcfg
+
+import (
+	"io"
+)
+
+// A Decoder reads gcfg data from an input stream into config structs. The
+// options it carries apply to each of its reads only, so that decoders with
+// different options can be used concurrently: for example, type parsers given
+// with UseTypeParser, the handling of unknown sections and variables with
+// WarnUnknown or CollectUnknown, CollectErrors, EnvPrefix, MaxSize and
+// SourceName.
+type Decoder struct {
+	r    io.Reader
+	opts []ReadOption
+}
+
+// NewDecoder returns a Decoder reading from r with options opts.
+func NewDecoder(r io.Reader, opts ...ReadOption) *Decoder {
+	return &Decoder{r: r, opts: opts}
+}
+
+// Decode reads the gcfg data from the input of d up to EOF and sets the
+// values into the corresponding fields in config, as ReadInto does.
+func (d *Decoder) Decode(config interface{}) error {
+	return ReadSourcesInto(config, []Source{ReaderSource("", d.r)}, d.opts...)
+}
+
+// An Encoder writes config structs as gcfg data to an output stream. The
+// options it carries apply to each of its writes only, so that encoders with
+// different options can be used concurrently: for example, type formatters
+// given with UseTypeFormatter, EmitZeroValues and BlankBools.
+type Encoder struct {
+	w    io.Writer
+	opts []WriteOption
+}
+
+// NewEncoder returns an Encoder writing to w with options opts.
+func NewEncoder(w io.Writer, opts ...WriteOption) *Encoder {
+	return &Encoder{w: w, opts: opts}
+}
+
+// Encode writes config to the output of e, as Write does.
+func (e *Encoder) Encode(config interface{}) error {
+	return Write(config, e.w, e.opts...)
+}
@@ -0,0 +1,120 @@
+package gcfg
+
+import (
+	"bytes"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+)
+
+type cCodecPair struct {
+	A, B string
+}
+
+type cCodec struct {
+	Section struct {
+		Pair cCodecPair
+		Name string
+	}
+}
+
+func pairParser(sep string) TypeParser {
+	return func(blank bool, val string) (interface{}, error) {
+		s := strings.SplitN(val, sep, 2)
+		if len(s) != 2 {
+			return nil, fmt.Errorf("missing %q", sep)
+		}
+		return &cCodecPair{s[0], s[1]}, nil
+	}
+}
+
+var typeCodecPair = reflect.TypeOf(cCodecPair{})
+
+func TestDecoderTypeParser(t *testing.T) {
+	var wg sync.WaitGroup
+	for _, sep := range []string{":", "/"} {
+		wg.Add(1)
+		go func(sep string) {
+			defer wg.Done()
+			for i := 0; i < 100; i++ {
+				cfg := &cCodec{}
+				in := "[section]\npair=a" + sep + "b"
+				d := NewDecoder(strings.NewReader(in), UseTypeParser(typeCodecPair, pairParser(sep)))
+				if err := d.Decode(cfg); err != nil {
+					t.Errorf("%q: %v", sep, err)
+					return
+				}
+				if exp := (cCodecPair{"a", "b"}); cfg.Section.Pair != exp {
+					t.Errorf("%q: got %+v, want %+v", sep, cfg.Section.Pair, exp)
+					return
+				}
+			}
+		}(sep)
+	}
+	wg.Wait()
+	if err := ReadStringInto(&cCodec{}, "[section]\npair=a:b"); err == nil {
+		t.Errorf("parser used without option")
+	}
+}
+
+func TestDecoderOptions(t *testing.T) {
+	in := "[section]\nname=file\nbad=1"
+	for i, tt := range []struct {
+		opts []ReadOption
+		name string
+		err  string
+	}{
+		{nil, "file", "bad"},
+		{[]ReadOption{WarnUnknown(func(*Error) {})}, "file", ""},
+		{[]ReadOption{SourceName("app.gcfg")}, "file", "app.gcfg:3:1"},
+		{[]ReadOption{MaxSize(int64(len(in))), CollectUnknown(&Extras{})}, "file", ""},
+		{[]ReadOption{MaxSize(int64(len(in) - 1))}, "", ErrTooLarge.Error()},
+		{[]ReadOption{EnvPrefix("GCFG_CODEC"), WarnUnknown(func(*Error) {})}, "env", ""},
+	} {
+		cfg := &cCodec{}
+		var err error
+		withEnv(t, map[string]string{"GCFG_CODEC_SECTION_NAME": "env"}, func() {
+			err = NewDecoder(strings.NewReader(in), tt.opts...).Decode(cfg)
+		})
+		switch {
+		case tt.err == "" && err != nil:
+			t.Errorf("%d: unexpected error: %v", i, err)
+		case tt.err != "" && (err == nil || !strings.Contains(err.Error(), tt.err)):
+			t.Errorf("%d: got error %v, want %q", i, err, tt.err)
+		case cfg.Section.Name != tt.name:
+			t.Errorf("%d: got name %q, want %q", i, cfg.Section.Name, tt.name)
+		}
+	}
+}
+
+func TestEncoderTypeFormatter(t *testing.T) {
+	cfg := &cCodec{}
+	cfg.Section.Pair = cCodecPair{"a", "b"}
+	f := func(v interface{}) string {
+		p := v.(cCodecPair)
+		return p.A + ":" + p.B
+	}
+	var buf bytes.Buffer
+	if err := NewEncoder(&buf, UseTypeFormatter(typeCodecPair, f)).Encode(cfg); err != nil {
+		t.Fatal(err)
+	}
+	if exp := "[section]\npair = a:b\n\n"; buf.String() != exp {
+		t.Errorf("got %q, want %q", buf.String(), exp)
+	}
+	res := &cCodec{}
+	if err := NewDecoder(&buf, UseTypeParser(typeCodecPair, pairParser(":"))).Decode(res); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(res, cfg) {
+		t.Errorf("read back %+v, want %+v", res, cfg)
+	}
+	buf.Reset()
+	if err := Write(cfg, &buf); err != nil {
+		t.Fatal(err)
+	}
+	if exp := "[section]\npair = {a b}\n\n"; buf.String() != exp {
+		t.Errorf("without option: got %q, want %q", buf.String(), exp)
+	}
+}
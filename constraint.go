@@ -2,19 +2,245 @@ package gcfg
 
 import (
 	"fmt"
+	"net"
+	"net/mail"
+	"path/filepath"
 	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
 	"github.com/baobabus/gcfg/types"
 )
 
 type constraints struct {
-	min     string
-	max     string
-	minlen  int
-	maxlen  int
+	min      string
+	max      string
+	minlen   int
+	maxlen   int
+	mincount int
+	maxcount int
+	pattern  *regexp.Regexp
+	oneof    []string
+	format   string
+}
+
+// formats holds the checks for the values of the "format" tag.
+var formats = map[string]func(s string) bool{
+	"hostname": isHostname,
+	"email":    isEmail,
+	"port":     isPort,
+	"cidr":     isCIDR,
+	"abspath":  filepath.IsAbs,
+}
+
+// isHostname reports whether s is a host name as specified in RFC 1123,
+// optionally ending with a dot.
+func isHostname(s string) bool {
+	s = strings.TrimSuffix(s, ".")
+	if s == "" || len(s) > 253 {
+		return false
+	}
+	for _, l := range strings.Split(s, ".") {
+		if l == "" || len(l) > 63 || l[0] == '-' || l[len(l)-1] == '-' {
+			return false
+		}
+		for _, c := range l {
+			if !('a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9' || c == '-') {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// isEmail reports whether s is an email address without display name.
+func isEmail(s string) bool {
+	a, err := mail.ParseAddress(s)
+	return err == nil && a.Address == s
+}
+
+// isPort reports whether s is a decimal port number.
+func isPort(s string) bool {
+	_, err := strconv.ParseUint(s, 10, 16)
+	return err == nil
+}
+
+// isCIDR reports whether s is an IP address and prefix length in CIDR
+// notation.
+func isCIDR(s string) bool {
+	_, _, err := net.ParseCIDR(s)
+	return err == nil
+}
+
+// parseTextConstraints sets the pattern, oneof and format constraints in c
+// from tag.
+func parseTextConstraints(c *constraints, tag reflect.StructTag) error {
+	if p := tag.Get("pattern"); p != "" {
+		re, err := regexp.Compile(p)
+		if err != nil {
+			return fmt.Errorf("invalid pattern constraint (%s): %v", p, err)
+		}
+		c.pattern = re
+	}
+	if o := tag.Get("oneof"); o != "" {
+		for _, s := range strings.Split(o, ",") {
+			c.oneof = append(c.oneof, strings.TrimSpace(s))
+		}
+	}
+	if f := tag.Get("format"); f != "" {
+		if formats[f] == nil {
+			return fmt.Errorf("invalid format constraint (%s): unknown format", f)
+		}
+		c.format = f
+	}
+	return nil
+}
+
+var textUnmarshalerType = reflect.TypeOf((*textUnmarshaler)(nil)).Elem()
+
+// checkTextConstraints returns an error if the metadata of a variable of type
+// t has pattern, oneof or format constraints but the values of t, or of its
+// elements if multi-valued, are neither strings nor TextUnmarshalers.
+func checkTextConstraints(t reflect.Type, m metadata) error {
+	c := m.constraints
+	if c.pattern == nil && c.oneof == nil && c.format == "" {
+		return nil
+	}
+	et := elemType(t)
+	if et.Kind() == reflect.String || reflect.PtrTo(et).Implements(textUnmarshalerType) {
+		return nil
+	}
+	return fmt.Errorf("pattern, oneof and format constraints not supported for type %v", t)
+}
+
+// boundErrors returns the errors in the min and max constraints of the
+// variables in the section struct types of the config struct type t.
+//
+// Only the bounds of variables parsed by predefined setters are checked here;
+// those of types with a parser given as an option or registered, or parsed
+// by scanning, are checked when a value is set.
+func (rs *readState) boundErrors(t reflect.Type) []*Error {
+	var errs []*Error
+	for _, f := range structInfoOf(t).fields {
+		st := sectionType(f.typ)
+		if st == nil {
+			continue
+		}
+		for _, vf := range structInfoOf(st).fields {
+			m := vf.meta
+			if m.err != nil || m.constraints.min == "" && m.constraints.max == "" {
+				continue
+			}
+			if et := elemType(vf.typ); rs.hasParser(et) || !predefinedSetter(et) {
+				continue
+			}
+			if err := vf.boundSpecErr(); err != nil {
+				errs = append(errs, newError(TypeError, f.name, "", vf.name, err))
+			}
+		}
+	}
+	return errs
+}
+
+// elemType returns the type of the values of a variable of type t: the
+// element type of a multi-valued variable, dereferenced if an unnamed
+// pointer type.
+func elemType(t reflect.Type) reflect.Type {
+	if t.Name() == "" && t.Kind() == reflect.Slice {
+		t = t.Elem()
+	}
+	if t.Name() == "" && t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	return t
+}
+
+// hasParser reports whether the read has a parser for type t, given as an
+// option or registered.
+func (rs *readState) hasParser(t reflect.Type) bool {
+	if _, ok := rs.typeSetters[t]; ok {
+		return true
+	}
+	reg := rs.registry
+	if reg == nil {
+		reg = DefaultRegistry
+	}
+	s, _ := reg.lookup(t)
+	return s != nil
+}
+
+// predefinedSetter reports whether values of type t are parsed by a
+// predefined setter other than scanning: t is a predeclared type, has a
+// predefined type setter, or is a TextUnmarshaler.
+func predefinedSetter(t reflect.Type) bool {
+	if _, ok := typeSetters[t]; ok {
+		return true
+	}
+	if reflect.PtrTo(t).Implements(textUnmarshalerType) {
+		return true
+	}
+	_, ok := kindSetters[t.Kind()]
+	return ok && t.PkgPath() == "" && t.Name() != ""
+}
+
+// boundSpecErr returns an error if the min or max constraint of variable
+// field f cannot be parsed as a value of the field with the predefined
+// setters. The result is computed once per field.
+func (f *field) boundSpecErr() error {
+	f.boundsOnce.Do(func() {
+		m := f.meta
+		// parse as a plain value of the variable
+		bm := metadata{intMode: m.intMode, percent: m.percent,
+			constraints: constraints{minlen: -1, maxlen: -1, mincount: -1, maxcount: -1}}
+		for _, b := range []struct{ name, spec string }{
+			{"min", m.constraints.min}, {"max", m.constraints.max},
+		} {
+			if b.spec == "" {
+				continue
+			}
+			v := reflect.New(f.typ).Elem()
+			if err := setValue(v, bm, false, b.spec, setters); err != nil {
+				f.boundsErr = fmt.Errorf("invalid %s constraint (%s): %v", b.name, b.spec, err)
+				return
+			}
+		}
+	})
+	return f.boundsErr
+}
+
+// checkText checks s against the pattern, oneof and format constraints in the
+// metadata.
+func checkText(s string, t metadata) error {
+	c := t.constraints
+	if c.pattern != nil && !c.pattern.MatchString(s) {
+		return constraintError(FormatError, "Value %q does not match pattern %q", s, c.pattern)
+	}
+	if c.oneof != nil {
+		found := false
+		for _, o := range c.oneof {
+			found = found || s == o
+		}
+		if !found {
+			return constraintError(FormatError, "Value %q not one of %s", s, strings.Join(c.oneof, ", "))
+		}
+	}
+	if c.format != "" && !formats[c.format](s) {
+		return constraintError(FormatError, "Value %q is not a valid %s", s, c.format)
+	}
+	return nil
+}
+
+// Returns an *Error of kind k; the variable context is filled in by set().
+func constraintError(k ErrorKind, format string, a ...interface{}) error {
+	return &Error{Kind: k, Err: fmt.Errorf(format, a...)}
 }
 
 type boundaryGetter func(interface{}, string) (*reflect.Value, error)
 
+// comparer returns a negative number, zero or a positive number if the value
+// pointed to by a is less than, equal to or greater than that pointed to by b.
+type comparer func(a, b interface{}) int
+
 // Gets boundary value by scanning
 func scanBoundary(d interface{}, val string) (*reflect.Value, error) {
 	if val == "" { return nil, nil; }
@@ -35,13 +261,23 @@ func unmarshalBoundary(d interface{}, val string) (*reflect.Value, error) {
 	return r, nil
 }
 
+// boundString formats the value p points to for bounds errors, with the
+// String method of p if it has one.
+func boundString(p reflect.Value) string {
+	if s, ok := p.Interface().(fmt.Stringer); ok {
+		return s.String()
+	}
+	return fmt.Sprintf("%v", p.Elem().Interface())
+}
+
 // Checks whether d is within bounds specified in the metadata.
-// This is only applicable to ordered types.
-func checkBounds(d interface{}, t metadata, bg boundaryGetter) error {
+// This is only applicable to ordered types, and to types ordered by cmp if
+// not nil.
+func checkBounds(d interface{}, t metadata, bg boundaryGetter, cmp comparer) error {
 	var obl, obh bool
 	var vs, ls, us string
-	min, err := bg(d, t.constraints.min); if err != nil { return err; }
-	max, err := bg(d, t.constraints.max); if err != nil { return err; }
+	min, err := bg(d, t.constraints.min); if err != nil { return constraintError(TypeError, "invalid min constraint (%s): %v", t.constraints.min, err); }
+	max, err := bg(d, t.constraints.max); if err != nil { return constraintError(TypeError, "invalid max constraint (%s): %v", t.constraints.max, err); }
 	if min != nil || max != nil {
 		// Hack aimed specifically at time.Time for now
 		// TODO add check for NumIn() and NumOut() and assert in and out types
@@ -49,7 +285,13 @@ func checkBounds(d interface{}, t metadata, bg boundaryGetter) error {
 		s := reflect.ValueOf(d).Elem()
 		lm := s.MethodByName("Less"); if lm == z { lm = s.MethodByName("Before"); }
 		gm := s.MethodByName("Greater"); if gm == z { gm = s.MethodByName("After"); }
-		if lm != z && gm != z { // via explicit methods for ordering detection
+		if cmp != nil { // via registered ordering
+			obl = min != nil && cmp(d, min.Interface()) < 0
+			obh = max != nil && cmp(d, max.Interface()) > 0
+			if min != nil { ls = boundString(*min); }
+			if max != nil { us = boundString(*max); }
+			vs = boundString(reflect.ValueOf(d))
+		} else if lm != z && gm != z { // via explicit methods for ordering detection
 			obl = min != nil && lm.Call([]reflect.Value {min.Elem()})[0].Bool()
 			obh = max != nil && gm.Call([]reflect.Value {max.Elem()})[0].Bool()
 			if min != nil { ls = fmt.Sprintf("%v", min.Interface()); }
@@ -89,12 +331,12 @@ func checkBounds(d interface{}, t metadata, bg boundaryGetter) error {
 	}
 	if obl || obh {
 		if min != nil && max != nil {
-			return fmt.Errorf("Value %s out of bounds [%s, %s]", vs, ls, us)
+			return constraintError(BoundsError, "Value %s out of bounds [%s, %s]", vs, ls, us)
 		} else {
 			if min != nil {
-				return fmt.Errorf("Value %s out of bounds [%s, +∞)", vs, ls)
+				return constraintError(BoundsError, "Value %s out of bounds [%s, +∞)", vs, ls)
 			} else {
-				return fmt.Errorf("Value %s out of bounds (-∞, %s]", vs, us)
+				return constraintError(BoundsError, "Value %s out of bounds (-∞, %s]", vs, us)
 			}
 		}
 	}
@@ -121,15 +363,22 @@ func checkLength(d interface{}, t metadata) error {
 	}
 	switch {
 	case obl:
-		return fmt.Errorf("Value is too short")
+		return constraintError(LengthError, "Value is too short")
 	case obh:
-		return fmt.Errorf("Value is too long")
+		return constraintError(LengthError, "Value is too long")
 	}
 	return nil
 }
 
-func checkConstraints(d interface{}, t metadata, bg boundaryGetter) error {
-	if err := checkBounds(d, t, bg); err != nil { return err; }
+func checkConstraints(d interface{}, t metadata, bg boundaryGetter, cmp comparer) error {
+	if err := checkBounds(d, t, bg, cmp); err != nil { return err; }
 	if err := checkLength(d, t); err != nil { return err; }
+	// the text of TextUnmarshaler types is checked as read
+	if _, ok := d.(textUnmarshaler); ok {
+		return nil
+	}
+	if rv := reflect.ValueOf(d).Elem(); rv.Kind() == reflect.String {
+		return checkText(rv.String(), t)
+	}
 	return nil
 }
@@ -0,0 +1,115 @@
+package gcfg
+
+import (
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+type cText struct {
+	Section struct {
+		Name  string   `pattern:"^[a-z0-9-]+$"`
+		Level string   `oneof:"debug, info, warn"`
+		Hosts []string `format:"hostname"`
+		Email *string  `format:"email"`
+		Port  string   `format:"port"`
+		Net   string   `format:"cidr"`
+		Dir   string   `format:"abspath"`
+		IP    net.IP   `pattern:"^10\\."`
+	}
+}
+
+func TestTextConstraints(t *testing.T) {
+	for i, tt := range []struct {
+		in  string
+		err string
+	}{
+		{"name=web-1\nlevel=info\nhosts=example.com\nhosts=a.b-c.example.\n" +
+			"email=a@example.com\nport=8080\nnet=10.0.0.0/8\ndir=/var/lib\nip=10.1.2.3", ""},
+		{"name=Web", `"Web" does not match pattern`},
+		{"level=warn", ""},
+		{"level=trace", `"trace" not one of debug, info, warn`},
+		{"hosts=ok\nhosts=-bad", "value 2: Value \"-bad\" is not a valid hostname"},
+		{"hosts=a..b", "not a valid hostname"},
+		{"email=A <a@example.com>", "not a valid email"},
+		{"port=65536", "not a valid port"},
+		{"net=10.0.0.0", "not a valid cidr"},
+		{"dir=var/lib", "not a valid abspath"},
+		{"ip=192.168.0.1", `"192.168.0.1" does not match pattern`},
+	} {
+		err := ReadStringInto(&cText{}, "[section]\n"+tt.in)
+		if tt.err == "" {
+			if err != nil {
+				t.Errorf("%d: unexpected error %v", i, err)
+			}
+			continue
+		}
+		if e, ok := err.(*Error); !ok || e.Kind != FormatError || !strings.Contains(e.Error(), tt.err) {
+			t.Errorf("%d: got error %v, want format error containing %q", i, err, tt.err)
+		}
+	}
+}
+
+func TestInvalidConstraintSpecs(t *testing.T) {
+	for i, tt := range []struct {
+		config interface{}
+		err    string
+	}{
+		{&struct {
+			Section struct {
+				Name string `pattern:"[a-"`
+			}
+		}{}, "invalid pattern constraint ([a-)"},
+		{&struct {
+			Section struct {
+				Name string `format:"uuid"`
+			}
+		}{}, "invalid format constraint (uuid)"},
+		{&struct {
+			Section struct {
+				Name string `minlen:"x"`
+			}
+		}{}, "invalid minlen constraint (x)"},
+		{&struct {
+			Sub map[string]*struct{ Name string } `mincount:"x"`
+		}{}, "invalid mincount constraint (x)"},
+		{&struct {
+			Section struct {
+				N int `min:"abc"`
+				M int
+			}
+		}{}, "invalid min constraint (abc)"},
+		{&struct {
+			Sub map[string]*struct {
+				F []float64 `max:"1x"`
+			}
+		}{}, "invalid max constraint (1x)"},
+		{&struct {
+			Section struct {
+				N int `pattern:"^x$"`
+			}
+		}{}, "not supported for type int"},
+		{&struct {
+			Section struct {
+				N []*int `oneof:"1,2"`
+			}
+		}{}, "not supported for type []*int"},
+	} {
+		// reported even if the variable is not set
+		err := ReadStringInto(tt.config, "[section]\nm=1")
+		if e, ok := err.(*Error); !ok || e.Kind != TypeError || !strings.Contains(e.Error(), tt.err) {
+			t.Errorf("%d: got error %v, want type error containing %q", i, err, tt.err)
+		}
+	}
+	// bounds of types without a predefined parser are only parsed when set
+	res := &struct {
+		Section struct{ M int }
+		Timing  struct {
+			D time.Duration `min:"1h"`
+		}
+	}{}
+	if err := ReadStringInto(res, "[section]\nm=1"); err != nil {
+		t.Errorf("got error %v for bounds of a section not read", err)
+	}
+}
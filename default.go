@@ -0,0 +1,105 @@
+package gcfg
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// fieldKey identifies a field of a particular config value.
+type fieldKey struct {
+	addr uintptr
+	typ  reflect.Type
+}
+
+func fieldKeyOf(v reflect.Value) fieldKey {
+	return fieldKey{v.UnsafeAddr(), v.Type()}
+}
+
+// setConfigDefaults sets the defaults for the struct-valued sections of
+// config.
+func (rs *readState) setConfigDefaults(config interface{}) {
+	vc := reflect.ValueOf(config)
+	if vc.Kind() != reflect.Ptr || vc.Elem().Kind() != reflect.Struct {
+		panic(fmt.Errorf("config must be a pointer to a struct"))
+	}
+	rs.setSectionDefaults(vc.Elem(), nil)
+}
+
+// setSectionDefaults sets the defaults for the struct-valued sections of
+// config struct vc whose index sequence begins with prefix.
+func (rs *readState) setSectionDefaults(vc reflect.Value, prefix []int) {
+	for _, f := range structInfoOf(vc.Type()).fields {
+		if !hasPrefix(f.index, prefix) {
+			continue
+		}
+		if vSect := fieldByIndex(vc, f.index, nil); vSect.Kind() == reflect.Struct {
+			rs.setDefaults(vSect, f.name, "")
+		}
+	}
+}
+
+// sectionAlloc returns the function for fieldFold and fieldByIndex setting
+// the defaults for the struct-valued sections of config struct vc reached
+// through the embedded pointers allocated.
+func (rs *readState) sectionAlloc(vc reflect.Value) func(prefix []int) {
+	return func(prefix []int) { rs.setSectionDefaults(vc, prefix) }
+}
+
+// varAlloc returns the function for fieldFold and fieldByIndex setting the
+// defaults for the variables of section struct vSect reached through the
+// embedded pointers allocated.
+func (rs *readState) varAlloc(vSect reflect.Value, sect, sub string) func(prefix []int) {
+	return func(prefix []int) { rs.setVarDefaults(vSect, prefix, sect, sub) }
+}
+
+// setDefaults sets the variables in section struct vSect that hold the zero
+// value to the values given by their "default" tags. The values are parsed as
+// if read from gcfg data; for multi-valued variables, the tag holds values
+// separated by commas, or by the separator given by the "defaultsep" tag.
+// Values later read for a multi-valued variable replace its default values.
+// Nil embedded pointers on the way to variables with defaults are allocated.
+func (rs *readState) setDefaults(vSect reflect.Value, sect, sub string) {
+	rs.setVarDefaults(vSect, nil, sect, sub)
+}
+
+// setVarDefaults sets the defaults for the variables of section struct vSect
+// whose index sequence begins with prefix, as setDefaults does.
+func (rs *readState) setVarDefaults(vSect reflect.Value, prefix []int, sect, sub string) {
+	for _, f := range structInfoOf(vSect.Type()).fields {
+		def := f.tag.Get("default")
+		if def == "" || !hasPrefix(f.index, prefix) {
+			continue
+		}
+		vVar := fieldByIndex(vSect, f.index, rs.varAlloc(vSect, sect, sub))
+		if !reflect.DeepEqual(vVar.Interface(), reflect.Zero(vVar.Type()).Interface()) {
+			continue
+		}
+		t := f.meta
+		values := []string{def}
+		if isMulti(vVar) {
+			sep := f.tag.Get("defaultsep")
+			if sep == "" {
+				sep = ","
+			}
+			values = strings.Split(def, sep)
+		}
+		for _, val := range values {
+			err := t.err
+			if err == nil {
+				err = setValue(vVar, t, false, val, rs.setters())
+			}
+			if err != nil {
+				vVar.Set(reflect.Zero(vVar.Type()))
+				e := newError(TypeError, sect, sub, f.name,
+					fmt.Errorf("invalid default value %q: %v", val, err))
+				e.Value = val
+				rs.report(e)
+				break
+			}
+		}
+		if isMulti(vVar) && vVar.Len() > 0 {
+			rs.defaulted[fieldKeyOf(vVar)] = true
+		}
+	}
+}
@@ -0,0 +1,119 @@
+package gcfg
+
+import (
+	"math/big"
+	"reflect"
+	"testing"
+)
+
+type cDefault struct {
+	Section cDefaultS1
+	Ptr     *cDefaultS1
+	Sub     map[string]*cDefaultS1
+}
+type cDefaultS1 struct {
+	Name     string        `default:"dflt"`
+	Int      int           `default:"0x10"`
+	Big      *big.Int      `default:"7"`
+	Text     unmarshalable `default:"text"`
+	Multi    []string      `default:"a,b"`
+	MultiSep []int         `default:"1;2" defaultsep:";"`
+	Plain    string
+}
+
+func newDefaultS1() *cDefaultS1 {
+	return &cDefaultS1{Name: "dflt", Int: 16, Big: big.NewInt(7), Text: "text",
+		Multi: []string{"a", "b"}, MultiSep: []int{1, 2}}
+}
+
+func TestDefaults(t *testing.T) {
+	s1 := newDefaultS1()
+	s2 := newDefaultS1()
+	s2.Name, s2.Multi = "file", []string{"c"}
+	s3 := newDefaultS1()
+	s3.Multi = nil
+	for i, tt := range []struct {
+		gcfg string
+		exp  *cDefault
+	}{
+		{"", &cDefault{Section: *s1}},
+		// values read override defaults; multi-valued defaults are replaced
+		{"[section]\nname=file\nmulti=c", &cDefault{Section: *s2}},
+		{"[section]\nmulti", &cDefault{Section: *s3}},
+		// pointer sections and subsections get defaults when allocated
+		{"[ptr]\nplain=x", &cDefault{Section: *s1, Ptr: &cDefaultS1{Name: "dflt", Int: 16,
+			Big: big.NewInt(7), Text: "text",
+			Multi: []string{"a", "b"}, MultiSep: []int{1, 2}, Plain: "x"}}},
+		{"[sub \"a\"]\nname=file\nmulti=c\n[sub \"b\"]\nplain=", &cDefault{Section: *s1,
+			Sub: map[string]*cDefaultS1{"a": s2, "b": s1}}},
+	} {
+		res := &cDefault{}
+		if err := ReadStringInto(res, tt.gcfg); err != nil {
+			t.Errorf("%d fail: got error %v, wanted ok", i, err)
+		} else if !reflect.DeepEqual(res, tt.exp) {
+			t.Errorf("%d fail: got value %#v, wanted value %#v", i, res, tt.exp)
+		}
+	}
+	// preset values are kept
+	res := &cDefault{Section: cDefaultS1{Name: "preset"}}
+	if err := ReadStringInto(res, ""); err != nil || res.Section.Name != "preset" {
+		t.Errorf("got %q, %v, wanted %q", res.Section.Name, err, "preset")
+	}
+}
+
+type CDefaultCommon struct {
+	Owner string   `default:"ops"`
+	Tags  []string `default:"x,y"`
+}
+
+type cDefaultEmbed struct {
+	*CDefaultCommon
+	Note string
+}
+
+type CDefaultSections struct {
+	Extra cDefaultEmbed
+}
+
+type cDefaultEmbedded struct {
+	*CDefaultSections
+	S   cDefaultEmbed
+	Sub map[string]*cDefaultEmbed
+}
+
+func TestDefaultsEmbedded(t *testing.T) {
+	res := &cDefaultEmbedded{}
+	err := ReadStringInto(res, "[s]\nnote=y\n[sub \"a\"]\nnote=z\n[sub \"b\"]\ntags=t\n[extra]\nnote=e")
+	if err != nil {
+		t.Fatal(err)
+	}
+	common := &CDefaultCommon{Owner: "ops", Tags: []string{"x", "y"}}
+	// embedded pointers get defaults when allocated
+	if !reflect.DeepEqual(res.S.CDefaultCommon, common) {
+		t.Errorf("section: got %#v, wanted %#v", res.S.CDefaultCommon, common)
+	}
+	if c := res.Sub["a"].CDefaultCommon; !reflect.DeepEqual(c, common) {
+		t.Errorf("subsection a: got %#v, wanted %#v", c, common)
+	}
+	// values read replace multi-valued defaults
+	exp := &CDefaultCommon{Owner: "ops", Tags: []string{"t"}}
+	if c := res.Sub["b"].CDefaultCommon; !reflect.DeepEqual(c, exp) {
+		t.Errorf("subsection b: got %#v, wanted %#v", c, exp)
+	}
+	// sections reached through embedded pointers in the config too
+	if res.CDefaultSections == nil || !reflect.DeepEqual(res.Extra.CDefaultCommon, common) {
+		t.Errorf("extra: got %#v, wanted %#v", res.CDefaultSections, common)
+	}
+}
+
+func TestDefaultsInvalid(t *testing.T) {
+	res := &struct {
+		Section struct {
+			Int int `default:"x"`
+		}
+	}{}
+	err := ReadStringInto(res, "")
+	if e, ok := err.(*Error); !ok || e.Kind != TypeError || e.Variable != "int" {
+		t.Errorf("got error %#v, wanted type error for variable int", err)
+	}
+}
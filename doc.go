@@ -11,7 +11,9 @@
 // There are some (planned) differences compared to the git config format:
 //  - improve data portability:
 //    - must be encoded in UTF-8 (for now) and must not contain the 0 byte
-//    - include and "path" type is not supported
+//    - include is only supported when enabled with the AllowIncludes option,
+//      and includeIf supports env: and hostname: conditions instead of gitdir:
+//    - "path" type is not supported
 //      (path type may be implementable as a user-defined type)
 //  - internationalization
 //    - section and variable names can contain unicode letters, unicode digits
@@ -22,8 +24,25 @@
 //      minimum and maximum values
 //    - mapping of data fields of string types can optionally
 //      specify minimum and maximum length
-//    - (planned) mapping of data fields of slice types can optionally
-//      specify minimum and maximum element count
+//    - mapping of data fields of string types, and of types implementing
+//      encoding.TextUnmarshaler, can optionally specify a regular expression
+//      to match with the "pattern" tag, a comma-separated list of allowed
+//      values with the "oneof" tag (spaces around commas are ignored), and
+//      one of the formats "hostname", "email", "port", "cidr" and "abspath"
+//      with the "format" tag
+//    - mapping of data fields of slice types can optionally specify
+//      minimum and maximum element count with the "mincount" and "maxcount"
+//      tags, checked once all input is read; the constraints above apply to
+//      each element
+//    - sections and variables can be marked as required using the struct
+//      tag option ",required"; sections with subsections can specify the
+//      minimum and maximum number of subsections with the "mincount" and
+//      "maxcount" tags; a multi-valued variable reset by a blank value
+//      counts as set only once values follow
+//    - invalid constraints are reported as a TypeError before reading; min
+//      and max values are checked then if the field has a predeclared type,
+//      a math/big type or a TextUnmarshaler and no parser given as an option
+//      or registered, and otherwise when a value is set
 //  - disallow potentially ambiguous or misleading definitions:
 //    - `[sec.sub]` format is not allowed (deprecated in gitconfig)
 //    - `[sec ""]` is not allowed
@@ -47,6 +66,13 @@
 // letter that is neither upper- or lower-case, prefix the field name with 'X'.
 // (See https://code.google.com/p/go/issues/detail?id=5763#c4 .)
 //
+// The fields of embedded structs, and of embedded pointers to structs, are
+// promoted to the embedding struct following the rules for Go selectors;
+// embedded pointers are allocated when one of their fields is set. Fields that
+// would be ambiguous selectors, and fields at the same depth mapped to the
+// same section or variable name, make the type invalid; reading into it and
+// writing it fail with a TypeError naming the conflicting fields.
+//
 // For sections with subsections, the corresponding field in config must be a
 // map, rather than a struct, with string keys and pointer-to-struct values.
 // Values for subsection variables are stored in the map with the subsection
@@ -93,9 +119,26 @@
 // ",int=mode" where mode is a combination of the 'd', 'h', and 'o' characters
 // (each standing for decimal, hexadecimal, and octal, respectively.)
 //
+// Fields of float kinds, big.Float and big.Rat are parsed as decimal numbers
+// with optional exponent, such as "1.5e3"; big.Rat also accepts fractions such
+// as "3/4". Fields of complex kinds take a real part, an imaginary part ending
+// in 'i', or both, as in "1+2i". Underscores may separate digits. NaN is not
+// accepted for float and complex kinds, nor are values out of their range.
+// With the struct tag option ",percent", values of float kinds, big.Float and
+// big.Rat may end in '%' and are then divided by 100, so that "75%" is read as
+// 0.75.
+// The min and max bounds of big.Int, big.Float and big.Rat fields are compared
+// exactly.
+//
 // Custom parser can be registered using RegisterTypeParser() function.
 // This is useful for types that require special handling  and which don't
 // implement encoding.TextUnmarshaler interface (such as time.Duration).
+// It registers the parser in DefaultRegistry; a Registry can also hold a
+// parser for all types implementing an interface, and a separate Registry can
+// be given to a read with UseRegistry. With Go 1.18 or later, the generic
+// RegisterParser and RegisterFormatter take functions for a type parameter T,
+// and RegisterOrder orders the values of a registered type for min and max
+// bounds.
 //
 // All other types are parsed using fmt.Sscanf with the "%v" verb.
 //
@@ -104,9 +147,93 @@
 // (variable name without equals sign and value), a new slice is allocated;
 // that is any values previously set in the slice will be ignored.
 //
+// By default, a section or variable without a corresponding field is an
+// error. With the WarnUnknown or CollectUnknown option, such variables are
+// passed to a callback or collected in an Extras list instead. A section
+// struct may also have a field of type map[string][]string with the
+// struct tag option ",rest", which receives the values of the variables
+// in that section that have no field, keyed by lower case variable name.
+// A ",rest" field of any other type is a TypeError.
+//
+// Default values can be given with the "default" struct tag, and are parsed
+// the same way as values read. They are set for variables holding the zero
+// value in the struct-valued sections of config when reading starts, and in
+// each pointer-to-struct section and subsection when it is allocated. The
+// default for a multi-valued variable is a comma-separated list of values (or
+// separated by the string in the "defaultsep" tag), which is replaced by any
+// values read for the variable.
+//
+// The struct tag option "cb=Method" names a method of the struct declaring
+// the variable field, called through a pointer to the struct after each value
+// is set (except blank values of multi-valued variables). The method takes no
+// arguments or a Definition describing the value read, and returns nothing or
+// an error, which fails the read as a CallbackError. A missing method, or one
+// of another type, is a TypeError.
+//
 // The types subpackage for provides helpers for parsing "enum-like" and integer
 // types.
 //
+// Multiple inputs
+//
+// ReadSourcesInto and ReadFilesInto read several inputs (files, readers or
+// strings) into the same config, in order. Single-valued variables set by a
+// later input override those set by an earlier one; values of multi-valued
+// variables accumulate unless reset with a blank value; and subsections of the
+// same name are merged.
+//
+// Writing
+//
+// Write writes a config as gcfg data that reads back into the same values,
+// using the same section and variable names as reading. By default, variables
+// holding the zero value of their type are left out; see EmitZeroValues. The
+// struct tag option ",omitempty" leaves out a variable holding the zero value
+// in any case, and a section without variables to write.
+//
+// Decoders and encoders
+//
+// Decoder and Encoder read and write gcfg data with options that apply to
+// them only, such as type parsers and formatters given with UseTypeParser and
+// UseTypeFormatter instead of the package-wide RegisterTypeParser and
+// RegisterTypeFormatter, so that parts of a program can use different rules.
+// The options can also be passed to the Read* functions and Write.
+//
+// Document trees
+//
+// Package github.com/baobabus/gcfg/ast parses gcfg data into a tree of
+// sections, variables and comments that can be inspected and modified without
+// a config struct. ASTSource reads such a tree into a config. Package
+// github.com/baobabus/gcfg/edit changes individual values in gcfg data while
+// keeping its comments and layout.
+//
+// Environment variables
+//
+// EnvSource provides values from environment variables, either named
+// explicitly with the "env" struct tag, or named after the section,
+// subsection and variable with a common prefix. Used as the last input of
+// ReadSourcesInto, it overrides the values from gcfg files.
+//
+// Command-line overrides
+//
+// Overrides holds variable definitions given as "section.variable=value" or
+// "section.subsection.variable=value", like the -c option of git. It
+// implements flag.Value for use with the flag package, and it is a Source
+// that can be used as the last input of ReadSourcesInto.
+//
+// Errors
+//
+// Reading stops at the first error, which is returned as an *Error. The
+// Error value holds the position, the section, subsection and variable at
+// fault, the raw value and an ErrorKind classifying the problem.
+// With the CollectErrors option, reading continues at the next line after
+// an error, and all errors are returned as a scanner.ErrorList whose entries
+// hold the corresponding *Error values.
+//
+// Once all inputs are read without errors, the Validate method of each
+// section struct, subsection struct and the config struct implementing
+// Validator is called, followed by the hooks given with ValidateConfig for
+// checks across sections. An error returned fails the read as a
+// ValidationError, at the position of the section header.
+//
 // TODO
 //
 // The following is a list of changes under consideration:
@@ -118,13 +245,8 @@
 //    - reconsider valid escape sequences
 //      (gitconfig doesn't support \r in value, \t in subsection name, etc.)
 //  - reading / parsing gcfg files
-//    - define internal representation structure
-//    - support multiple inputs (readers, strings, files)
 //    - support declaring encoding (?)
 //    - support varying fields sets for subsections (?)
 //  - writing gcfg files
-//  - error handling
-//    - make error context accessible programmatically?
-//    - limit input size?
 //
 package gcfg
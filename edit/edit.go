@@ -0,0 +1,302 @@
+// Package edit modifies gcfg data in the manner of git config, rewriting only
+// the lines affected by a change and keeping everything else byte-for-byte,
+// including comments, ordering, quoting and line endings.
+package edit
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+import (
+	"github.com/baobabus/gcfg/ast"
+	"github.com/baobabus/gcfg/token"
+)
+
+// ErrNotFound is returned when the section or variable to change is not
+// defined.
+var ErrNotFound = errors.New("not found")
+
+// An Editor holds gcfg data being edited.
+type Editor struct {
+	name string
+	src  []byte
+	file *token.File
+	tree *ast.File
+}
+
+// New returns an Editor for the gcfg data src read from the file filename.
+// Syntax errors in src are returned as a scanner.ErrorList.
+func New(filename string, src []byte) (*Editor, error) {
+	e := &Editor{name: filename}
+	if err := e.parse(src); err != nil {
+		return nil, err
+	}
+	return e, nil
+}
+
+// parse makes src the data being edited.
+func (e *Editor) parse(src []byte) error {
+	fset := token.NewFileSet()
+	tree, err := ast.Parse(fset, e.name, src)
+	if err != nil {
+		return err
+	}
+	fset.Iterate(func(f *token.File) bool {
+		e.file = f
+		return false
+	})
+	e.src, e.tree = src, tree
+	return nil
+}
+
+// Bytes returns the edited data. The slice must not be modified.
+func (e *Editor) Bytes() []byte {
+	return e.src
+}
+
+// File returns the document tree of the edited data, with positions relative
+// to its start. The tree must not be modified; it is replaced after each edit.
+func (e *Editor) File() *ast.File {
+	return e.tree
+}
+
+// change replaces the bytes from start to end with text.
+type change struct {
+	start, end int
+	text       string
+}
+
+// apply makes the changes cs, which must not overlap.
+func (e *Editor) apply(cs ...change) error {
+	sort.Sort(byStart(cs))
+	var b bytes.Buffer
+	off := 0
+	for _, c := range cs {
+		b.Write(e.src[off:c.start])
+		b.WriteString(c.text)
+		off = c.end
+	}
+	b.Write(e.src[off:])
+	return e.parse(b.Bytes())
+}
+
+type byStart []change
+
+func (cs byStart) Len() int           { return len(cs) }
+func (cs byStart) Swap(i, j int)      { cs[i], cs[j] = cs[j], cs[i] }
+func (cs byStart) Less(i, j int) bool { return cs[i].start < cs[j].start }
+
+func (e *Editor) offset(p token.Pos) int {
+	return e.file.Offset(p)
+}
+
+// lineStart returns the offset of the start of the line containing p.
+func (e *Editor) lineStart(p token.Pos) int {
+	return bytes.LastIndex(e.src[:e.offset(p)], []byte("\n")) + 1
+}
+
+// lineEnd returns the offset following the line break at end, or the offset
+// of the end of the data if end is at EOF.
+func (e *Editor) lineEnd(end token.Pos) int {
+	off := e.offset(end)
+	if off < len(e.src) {
+		off++
+	}
+	return off
+}
+
+// eol returns the line break used in the data.
+func (e *Editor) eol() string {
+	if i := bytes.IndexByte(e.src, '\n'); i > 0 && e.src[i-1] == '\r' {
+		return "\r\n"
+	}
+	return "\n"
+}
+
+// removeLines returns the change removing the lines from the one containing
+// start up to the one ended at end.
+func (e *Editor) removeLines(start, end token.Pos) change {
+	return change{e.lineStart(start), e.lineEnd(end), ""}
+}
+
+// insertLines returns the change inserting lines, each ended by a line
+// break, after the line ended at end, or at the end of the data if end is
+// NoPos.
+func (e *Editor) insertLines(end token.Pos, lines ...string) change {
+	eol := e.eol()
+	text := strings.Join(lines, eol) + eol
+	off := len(e.src)
+	if end.IsValid() {
+		off = e.lineEnd(end)
+	}
+	if off == len(e.src) && off > 0 && e.src[off-1] != '\n' {
+		text = eol + strings.TrimSuffix(text, eol)
+	}
+	return change{off, off, text}
+}
+
+// setValue returns the change setting the value of v, keeping the quoting
+// style of its current value.
+func (e *Editor) setValue(v *ast.Variable, value string) (change, error) {
+	q, err := ast.QuoteValue(value)
+	if err != nil {
+		return change{}, err
+	}
+	if v.Blank {
+		off := e.offset(v.Pos) + len(v.Name)
+		return change{off, off, " = " + q}, nil
+	}
+	start, end := e.offset(v.ValuePos), e.offset(v.End)
+	if v.Comment != nil {
+		end = e.offset(v.Comment.Pos)
+	}
+	end = start + len(bytes.TrimRight(e.src[start:end], " \t\r"))
+	if old := e.src[start:end]; len(old) >= 2 && old[0] == '"' && old[len(old)-1] == '"' &&
+		q[0] != '"' {
+		q = `"` + q + `"`
+	}
+	return change{start, end, q}, nil
+}
+
+// varLine returns the line defining the variable name with value. It is
+// indented and spaced like the definition like, or if like is nil, like the
+// last definition in the data.
+func (e *Editor) varLine(like *ast.Variable, name, value string) (string, error) {
+	if !ast.IsName(name) {
+		return "", fmt.Errorf("invalid variable name %q", name)
+	}
+	q, err := ast.QuoteValue(value)
+	if err != nil {
+		return "", err
+	}
+	if like == nil {
+		for _, s := range e.tree.Sections {
+			if len(s.Vars) > 0 {
+				like = s.Vars[len(s.Vars)-1]
+			}
+		}
+	}
+	indent, sep := "", " = "
+	if like != nil {
+		indent = string(e.src[e.lineStart(like.Pos):e.offset(like.Pos)])
+		if !like.Blank {
+			sep = string(e.src[e.offset(like.Pos)+len(like.Name) : e.offset(like.ValuePos)])
+		}
+	}
+	return indent + name + sep + q, nil
+}
+
+// SetValue sets the variable name in section sect and subsection sub to
+// value. The value of the last definition is replaced, keeping its quoting
+// style, and the other definitions are removed. If the variable is not
+// defined, it is added as by AddValue.
+func (e *Editor) SetValue(sect, sub, name, value string) error {
+	vars := e.tree.Lookup(sect, sub, name)
+	if len(vars) == 0 {
+		return e.AddValue(sect, sub, name, value)
+	}
+	last := vars[len(vars)-1]
+	c, err := e.setValue(last, value)
+	if err != nil {
+		return err
+	}
+	cs := []change{c}
+	for _, v := range vars[:len(vars)-1] {
+		cs = append(cs, e.removeLines(v.Pos, v.End))
+	}
+	return e.apply(cs...)
+}
+
+// AddValue adds a definition of the variable name with value after the last
+// variable of the last header for section sect and subsection sub, indented
+// and spaced like that variable. If there is no such header, one is added at
+// the end of the data.
+func (e *Editor) AddValue(sect, sub, name, value string) error {
+	var last *ast.Section
+	for _, s := range e.tree.Sections {
+		if s.Is(sect, sub) {
+			last = s
+		}
+	}
+	if last == nil {
+		h, err := ast.Header(sect, sub)
+		if err != nil {
+			return err
+		}
+		line, err := e.varLine(nil, name, value)
+		if err != nil {
+			return err
+		}
+		return e.apply(e.insertLines(token.NoPos, h, line))
+	}
+	var like *ast.Variable
+	end := last.End
+	if n := len(last.Vars); n > 0 {
+		like = last.Vars[n-1]
+		end = like.End
+	}
+	line, err := e.varLine(like, name, value)
+	if err != nil {
+		return err
+	}
+	return e.apply(e.insertLines(end, line))
+}
+
+// UnsetValue removes the definitions of the variable name in section sect and
+// subsection sub. It returns ErrNotFound if there are none.
+func (e *Editor) UnsetValue(sect, sub, name string) error {
+	vars := e.tree.Lookup(sect, sub, name)
+	if len(vars) == 0 {
+		return ErrNotFound
+	}
+	var cs []change
+	for _, v := range vars {
+		cs = append(cs, e.removeLines(v.Pos, v.End))
+	}
+	return e.apply(cs...)
+}
+
+// RenameSection changes the headers for section name and subsection sub to
+// name newName and subsection newSub. It returns ErrNotFound if there are
+// none.
+func (e *Editor) RenameSection(name, sub, newName, newSub string) error {
+	h, err := ast.Header(newName, newSub)
+	if err != nil {
+		return err
+	}
+	var cs []change
+	for _, s := range e.tree.Sections {
+		if s.Is(name, sub) {
+			cs = append(cs, change{e.offset(s.Pos), e.offset(s.Rbrack) + 1, h})
+		}
+	}
+	if len(cs) == 0 {
+		return ErrNotFound
+	}
+	return e.apply(cs...)
+}
+
+// RemoveSection removes the headers for section name and subsection sub,
+// together with their variables and the comment lines among them. It returns
+// ErrNotFound if there are none.
+func (e *Editor) RemoveSection(name, sub string) error {
+	var cs []change
+	for _, s := range e.tree.Sections {
+		if !s.Is(name, sub) {
+			continue
+		}
+		end := s.End
+		if n := len(s.Vars); n > 0 {
+			end = s.Vars[n-1].End
+		}
+		cs = append(cs, e.removeLines(s.Pos, end))
+	}
+	if len(cs) == 0 {
+		return ErrNotFound
+	}
+	return e.apply(cs...)
+}
@@ -0,0 +1,116 @@
+package edit
+
+import (
+	"strings"
+	"testing"
+)
+
+const editSrc = `; top comment
+[core]
+	name = value ; keep
+	quoted = "a b"
+	multi = 1
+	; between
+	multi = 2
+	flag
+
+[remote "origin"]
+	url=x
+`
+
+func TestEditor(t *testing.T) {
+	for i, tt := range []struct {
+		edit func(e *Editor) error
+		exp  string
+	}{
+		{func(e *Editor) error { return e.SetValue("core", "", "name", "new") },
+			strings.Replace(editSrc, "name = value ; keep", "name = new ; keep", 1)},
+		// quoting style is kept; values are quoted as needed
+		{func(e *Editor) error { return e.SetValue("core", "", "quoted", "c") },
+			strings.Replace(editSrc, `"a b"`, `"c"`, 1)},
+		{func(e *Editor) error { return e.SetValue("core", "", "name", "a;b") },
+			strings.Replace(editSrc, "value", `"a;b"`, 1)},
+		// the last definition is set and the others are removed
+		{func(e *Editor) error { return e.SetValue("core", "", "multi", "3") },
+			strings.Replace(editSrc, "\tmulti = 1\n\t; between\n\tmulti = 2", "\t; between\n\tmulti = 3", 1)},
+		{func(e *Editor) error { return e.SetValue("CORE", "", "Flag", "false") },
+			strings.Replace(editSrc, "\tflag\n", "\tflag = false\n", 1)},
+		// new definitions follow the layout of the section
+		{func(e *Editor) error { return e.AddValue("core", "", "multi", "3") },
+			strings.Replace(editSrc, "\tflag\n", "\tflag\n\tmulti = 3\n", 1)},
+		{func(e *Editor) error { return e.AddValue("remote", "origin", "fetch", "y") },
+			editSrc + "\tfetch=y\n"},
+		{func(e *Editor) error { return e.SetValue("new", "sub", "v", "1") },
+			editSrc + "[new \"sub\"]\n\tv=1\n"},
+		{func(e *Editor) error { return e.UnsetValue("core", "", "multi") },
+			strings.Replace(editSrc, "\tmulti = 1\n\t; between\n\tmulti = 2\n", "\t; between\n", 1)},
+		{func(e *Editor) error { return e.RenameSection("remote", "origin", "remote", "upstream") },
+			strings.Replace(editSrc, `[remote "origin"]`, `[remote "upstream"]`, 1)},
+		{func(e *Editor) error { return e.RemoveSection("core", "") },
+			"; top comment\n\n[remote \"origin\"]\n\turl=x\n"},
+	} {
+		e, err := New("", []byte(editSrc))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := tt.edit(e); err != nil {
+			t.Errorf("%d: got error %v", i, err)
+			continue
+		}
+		if got := string(e.Bytes()); got != tt.exp {
+			t.Errorf("%d: got\n%s\nwanted\n%s", i, got, tt.exp)
+		}
+	}
+}
+
+func TestEditorCRLF(t *testing.T) {
+	src := "[a]\r\nx = 1 \r\n# c\r\n[b]\r\ny = 2"
+	e, err := New("", []byte(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := e.SetValue("a", "", "x", "2"); err != nil {
+		t.Fatal(err)
+	}
+	if err := e.AddValue("a", "", "z", "3"); err != nil {
+		t.Fatal(err)
+	}
+	if err := e.AddValue("b", "", "w", "4"); err != nil {
+		t.Fatal(err)
+	}
+	exp := "[a]\r\nx = 2 \r\nz = 3\r\n# c\r\n[b]\r\ny = 2\r\nw = 4"
+	if got := string(e.Bytes()); got != exp {
+		t.Errorf("got %q, wanted %q", got, exp)
+	}
+}
+
+func TestEditorErrors(t *testing.T) {
+	e, err := New("", []byte(editSrc))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, err := range []error{
+		e.UnsetValue("core", "", "missing"),
+		e.RenameSection("missing", "", "x", ""),
+		e.RemoveSection("core", "sub"),
+	} {
+		if err != ErrNotFound {
+			t.Errorf("%d: got %v, wanted ErrNotFound", i, err)
+		}
+	}
+	for i, err := range []error{
+		e.AddValue("core", "", "bad name", "x"),
+		e.SetValue("core", "", "name", "\r"),
+		e.RenameSection("core", "", "core", "a\nb"),
+	} {
+		if err == nil {
+			t.Errorf("%d: got ok, wanted error", i)
+		}
+	}
+	if string(e.Bytes()) != editSrc {
+		t.Errorf("got %q after failed edits, wanted unchanged", e.Bytes())
+	}
+	if _, err := New("", []byte("[bad")); err == nil {
+		t.Errorf("got ok for invalid data, wanted error")
+	}
+}
@@ -0,0 +1,197 @@
+package gcfg
+
+import (
+	"fmt"
+	"os"
+	"reflect"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+type envSource struct {
+	prefix string
+}
+
+// EnvSource returns a Source setting variables from environment variables.
+//
+// A variable field tagged with `env:"NAME"` is set from the environment
+// variable NAME, if defined. For fields of sections with subsections, NAME
+// must contain a single '*', which matches the subsection name; e.g.
+// `env:"APP_SERVER_*_HOST"`.
+//
+// If prefix is not empty, each variable can also be set from an environment
+// variable named PREFIX_SECTION_VARIABLE or, for sections with subsections,
+// PREFIX_SECTION_SUBSECTION_VARIABLE; PREFIX_SECTION_VARIABLE then sets the
+// variable of the section without subsection name. These names are formed by
+// converting to upper case and replacing characters other than letters and
+// digits with '_'.
+//
+// A subsection name taken from an environment variable name refers to the
+// existing subsection whose name converts to it, or if there is none, to a new
+// subsection with the name converted to lower case.
+//
+// The values are set the same way as values read from gcfg data. A value for a
+// multi-valued variable replaces any values set earlier; if the field is
+// tagged with `envsep:"SEP"`, the value is split at each SEP into multiple
+// values. Error positions name the environment variable at fault.
+func EnvSource(prefix string) Source {
+	return envSource{prefix}
+}
+
+// EnvPrefix makes the read apply the environment variables with prefix, as
+// for EnvSource(prefix), after all its sources.
+func EnvPrefix(prefix string) ReadOption {
+	return func(rs *readState) { rs.envPrefix = prefix }
+}
+
+// envName returns s converted to the form used in environment variable names.
+func envName(s string) string {
+	return strings.Map(func(r rune) rune {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			return unicode.ToUpper(r)
+		}
+		return '_'
+	}, s)
+}
+
+// envVar is an environment variable applied to a config variable.
+type envVar struct {
+	name, value     string
+	sect, sub, vnam string
+	multi           bool
+	sep             string
+}
+
+func (s envSource) readInto(rs *readState, config interface{}) error {
+	vc := reflect.ValueOf(config)
+	if vc.Kind() != reflect.Ptr || vc.Elem().Kind() != reflect.Struct {
+		panic(fmt.Errorf("config must be a pointer to a struct"))
+	}
+	vc = vc.Elem()
+	env := map[string]string{}
+	var names []string
+	for _, kv := range os.Environ() {
+		if i := strings.Index(kv, "="); i > 0 {
+			env[kv[:i]] = kv[i+1:]
+			names = append(names, kv[:i])
+		}
+	}
+	sort.Strings(names)
+	var vars []envVar
+	for _, sf := range structInfoOf(vc.Type()).fields {
+		st := sectionType(sf.typ)
+		if st == nil {
+			continue
+		}
+		isMap := sf.typ.Kind() == reflect.Map
+		sect := sf.name
+		// for sections with subsections, environment variable names formed
+		// with prefix are matched against the longest variable name suffix
+		auto := map[string]envVar{}
+		for _, f := range structInfoOf(st).fields {
+			v := envVar{sect: sect, vnam: f.name, sep: f.tag.Get("envsep")}
+			v.multi = f.typ.Name() == "" && f.typ.Kind() == reflect.Slice
+			tag := f.tag.Get("env")
+			if !isMap {
+				name := tag
+				if name == "" && s.prefix != "" {
+					name = s.prefix + "_" + envName(sect) + "_" + envName(v.vnam)
+				}
+				if _, ok := env[name]; ok && name != "" {
+					v.name, v.value = name, env[name]
+					vars = append(vars, v)
+				}
+				continue
+			}
+			vm := fieldByIndex(vc, sf.index, nil)
+			if tag != "" {
+				i := strings.Index(tag, "*")
+				if i < 0 || strings.Count(tag, "*") > 1 {
+					rs.report(newError(TypeError, sect, "", v.vnam,
+						fmt.Errorf("env tag %q must contain a single '*'", tag)))
+					continue
+				}
+				pre, suf := tag[:i], tag[i+1:]
+				for _, name := range names {
+					if len(name) > len(pre)+len(suf) &&
+						strings.HasPrefix(name, pre) && strings.HasSuffix(name, suf) {
+						v.name, v.value = name, env[name]
+						v.sub = envSub(vm, name[len(pre):len(name)-len(suf)])
+						vars = append(vars, v)
+					}
+				}
+				continue
+			}
+			if s.prefix == "" {
+				continue
+			}
+			pre := s.prefix + "_" + envName(sect)
+			suf := "_" + envName(v.vnam)
+			for _, name := range names {
+				if !strings.HasPrefix(name, pre) || !strings.HasSuffix(name, suf) {
+					continue
+				}
+				sub := ""
+				if name != pre+suf {
+					if len(name) <= len(pre)+len(suf)+1 || name[len(pre)] != '_' {
+						continue
+					}
+					sub = envSub(vm, name[len(pre)+1:len(name)-len(suf)])
+				}
+				if a, ok := auto[name]; ok && len(envName(a.vnam)) >= len(suf)-1 {
+					continue
+				}
+				v.name, v.value, v.sub = name, env[name], sub
+				auto[name] = v
+			}
+		}
+		for _, name := range names {
+			if v, ok := auto[name]; ok {
+				vars = append(vars, v)
+			}
+		}
+	}
+	for _, v := range vars {
+		file := rs.fset.AddFile("$"+v.name, rs.fset.Base(), len(v.value))
+		file.SetLinesForContent([]byte(v.value))
+		values := []string{v.value}
+		if v.multi {
+			pos := rs.fset.Position(file.Pos(0))
+			if !rs.setAt(config, v.sect, v.sub, v.vnam, true, "", pos, pos) && !rs.collect {
+				return rs.err()
+			}
+			if v.value == "" {
+				values = nil
+			} else if v.sep != "" {
+				values = strings.Split(v.value, v.sep)
+			}
+		}
+		off := 0
+		for _, val := range values {
+			pos := rs.fset.Position(file.Pos(off))
+			if !rs.setAt(config, v.sect, v.sub, v.vnam, false, val, pos, pos) && !rs.collect {
+				return rs.err()
+			}
+			off += len(val) + len(v.sep)
+		}
+	}
+	if rs.collect {
+		return nil
+	}
+	return rs.err()
+}
+
+// envSub returns the name of the subsection of map section vm corresponding to
+// name as taken from an environment variable name.
+func envSub(vm reflect.Value, name string) string {
+	if !vm.IsValid() {
+		return strings.ToLower(name)
+	}
+	for _, k := range vm.MapKeys() {
+		if envName(k.String()) == name {
+			return k.String()
+		}
+	}
+	return strings.ToLower(name)
+}
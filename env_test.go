@@ -0,0 +1,118 @@
+package gcfg
+
+import (
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type cEnv struct {
+	Section cEnvS1
+	Sub     map[string]*cEnvS1
+	Tagged  map[string]*cEnvS2
+}
+type cEnvS1 struct {
+	Name      string
+	Int       int `min:"1"`
+	Port_Name string
+	Multi     []string `envsep:","`
+}
+type cEnvS2 struct {
+	Host string `env:"GCFG_TEST_TAGGED_*_HOST"`
+	Port int
+}
+type cEnvRequired struct {
+	Section struct {
+		Multi []string `gcfg:",required"`
+	}
+}
+type cEnvOther struct {
+	Section cEnvS1
+	Labels  map[string]string
+	Values  map[string]cEnvS1
+}
+type cEnvBad struct {
+	Tagged map[string]*struct {
+		Port int `env:"GCFG_TEST_PORT"`
+	}
+}
+
+func withEnv(t *testing.T, env map[string]string, fn func()) {
+	for k, v := range env {
+		os.Setenv(k, v)
+	}
+	defer func() {
+		for k := range env {
+			os.Unsetenv(k)
+		}
+	}()
+	fn()
+}
+
+func TestEnvSource(t *testing.T) {
+	for i, tt := range []struct {
+		gcfg string
+		env  map[string]string
+		exp  *cEnv
+	}{
+		{"[section]\nname=file\nint=1", map[string]string{"APP_SECTION_NAME": "env"},
+			&cEnv{Section: cEnvS1{Name: "env", Int: 1}}},
+		{"[section]\nmulti=file", map[string]string{"APP_SECTION_MULTI": "a,b"},
+			&cEnv{Section: cEnvS1{Multi: []string{"a", "b"}}}},
+		{"[section]\nmulti=file", map[string]string{"APP_SECTION_MULTI": ""},
+			&cEnv{Section: cEnvS1{}}},
+		// subsections: existing by converted name, new in lower case
+		{"[sub \"Alpha-1\"]\nname=file", map[string]string{
+			"APP_SUB_ALPHA_1_NAME": "env1", "APP_SUB_BETA_INT": "2", "APP_SUB_NAME": "env2"},
+			&cEnv{Sub: map[string]*cEnvS1{"Alpha-1": {Name: "env1"}, "beta": {Int: 2}, "": {Name: "env2"}}}},
+		// longest variable name suffix wins
+		{"", map[string]string{"APP_SUB_A_PORT_NAME": "port"},
+			&cEnv{Sub: map[string]*cEnvS1{"a": {Port_Name: "port"}}}},
+		// explicit tags
+		{"[tagged \"x\"]\nport=1", map[string]string{"GCFG_TEST_TAGGED_X_HOST": "h"},
+			&cEnv{Tagged: map[string]*cEnvS2{"x": {Host: "h", Port: 1}}}},
+	} {
+		res := &cEnv{}
+		withEnv(t, tt.env, func() {
+			err := ReadSourcesInto(res, []Source{StringSource("", tt.gcfg), EnvSource("APP")})
+			if err != nil {
+				t.Errorf("%d fail: got error %v, wanted ok", i, err)
+			} else if !reflect.DeepEqual(res, tt.exp) {
+				t.Errorf("%d fail: got value %#v, wanted value %#v", i, res, tt.exp)
+			}
+		})
+	}
+}
+
+func TestEnvSourceNonSections(t *testing.T) {
+	// map fields that are not sections are skipped
+	withEnv(t, map[string]string{"APP_SECTION_NAME": "env", "APP_LABELS_X": "y"}, func() {
+		res := &cEnvOther{}
+		err := ReadSourcesInto(res, []Source{EnvSource("APP")})
+		if err != nil || res.Section.Name != "env" || res.Labels != nil {
+			t.Errorf("got %#v, %v, wanted section name env", res, err)
+		}
+	})
+}
+
+func TestEnvSourceErrors(t *testing.T) {
+	withEnv(t, map[string]string{"APP_SECTION_INT": "0", "GCFG_TEST_PORT": "x"}, func() {
+		err := ReadSourcesInto(&cEnv{}, []Source{EnvSource("APP")})
+		if e, ok := err.(*Error); !ok || e.Kind != BoundsError || e.Pos.Filename != "$APP_SECTION_INT" {
+			t.Errorf("got error %#v, wanted bounds error for $APP_SECTION_INT", err)
+		}
+		err = ReadSourcesInto(&cEnvBad{}, []Source{EnvSource("")})
+		if e, ok := err.(*Error); !ok || e.Kind != TypeError || !strings.Contains(e.Error(), "GCFG_TEST_PORT") {
+			t.Errorf("got error %v, wanted type error for tag without '*'", err)
+		}
+	})
+	// an empty value resets a multi-valued variable without setting it
+	withEnv(t, map[string]string{"APP_SECTION_MULTI": ""}, func() {
+		err := ReadSourcesInto(&cEnvRequired{},
+			[]Source{StringSource("", "[section]\nmulti=a"), EnvSource("APP")})
+		if e, ok := err.(*Error); !ok || e.Kind != MissingError || e.Variable != "multi" {
+			t.Errorf("got error %v, wanted missing variable multi", err)
+		}
+	})
+}
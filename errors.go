@@ -0,0 +1,109 @@
+package gcfg
+
+import (
+	"fmt"
+	"strings"
+)
+
+import (
+	"github.com/baobabus/gcfg/token"
+)
+
+// ErrorKind classifies the errors reported when reading gcfg data.
+type ErrorKind int
+
+const (
+	SyntaxError      ErrorKind = iota // malformed gcfg data
+	UnknownSection                    // no field matches the section or subsection
+	UnknownVariable                   // no field matches the variable
+	ParseError                        // the value cannot be parsed for the field type
+	BoundsError                       // the value violates min or max constraint
+	LengthError                       // the value violates minlen or maxlen constraint
+	BlankUnsupported                  // blank value given for a type not supporting it
+	TypeError                         // the config type or its struct tags are invalid
+	IncludeError                      // an included file cannot be read
+	MissingError                      // a required section or variable is missing
+	CountError                        // the number of values or subsections violates mincount or maxcount constraint
+	FormatError                       // the value violates pattern, oneof or format constraint
+	ValidationError                   // a Validate method or validation hook failed
+	CallbackError                     // a callback given with the "cb" tag option failed
+)
+
+var errorKinds = [...]string{
+	SyntaxError:      "syntax error",
+	UnknownSection:   "unknown section",
+	UnknownVariable:  "unknown variable",
+	ParseError:       "parse error",
+	BoundsError:      "bounds error",
+	LengthError:      "length error",
+	BlankUnsupported: "blank unsupported",
+	TypeError:        "type error",
+	IncludeError:     "include error",
+	MissingError:     "missing error",
+	CountError:       "count error",
+	FormatError:      "format error",
+	ValidationError:  "validation error",
+	CallbackError:    "callback error",
+}
+
+func (k ErrorKind) String() string {
+	if 0 <= k && int(k) < len(errorKinds) {
+		return errorKinds[k]
+	}
+	return fmt.Sprintf("ErrorKind(%d)", int(k))
+}
+
+// Error describes a problem with gcfg data or with the config it is read
+// into. Section, Subsection and Variable identify the definition at fault as
+// spelled in the input, and Value holds its raw (unquoted) value; any of these
+// are empty when not applicable. Err holds the underlying cause.
+type Error struct {
+	Pos        token.Position
+	Kind       ErrorKind
+	Section    string
+	Subsection string
+	Variable   string
+	Value      string
+	Err        error
+}
+
+// msg returns the error message without position information.
+func (e *Error) msg() string {
+	var msg string
+	if e.Err != nil {
+		msg = e.Err.Error()
+	} else {
+		msg = e.Kind.String()
+	}
+	var ctx []string
+	if e.Section != "" {
+		ctx = append(ctx, fmt.Sprintf("section %q", e.Section))
+	}
+	if e.Subsection != "" {
+		ctx = append(ctx, fmt.Sprintf("subsection %q", e.Subsection))
+	}
+	if e.Variable != "" {
+		ctx = append(ctx, fmt.Sprintf("variable %q", e.Variable))
+	}
+	if len(ctx) > 0 {
+		msg += ": " + strings.Join(ctx, " ")
+	}
+	return msg
+}
+
+// Error implements the error interface.
+func (e *Error) Error() string {
+	if e.Pos.Filename != "" || e.Pos.IsValid() {
+		return e.Pos.String() + ": " + e.msg()
+	}
+	return e.msg()
+}
+
+// Unwrap returns the underlying cause.
+func (e *Error) Unwrap() error { return e.Err }
+
+// newError returns an *Error of kind k for the definition identified by sect,
+// sub and name.
+func newError(k ErrorKind, sect, sub, name string, err error) *Error {
+	return &Error{Kind: k, Section: sect, Subsection: sub, Variable: name, Err: err}
+}
@@ -0,0 +1,70 @@
+package gcfg
+
+import (
+	"testing"
+)
+
+import (
+	"github.com/baobabus/gcfg/scanner"
+)
+
+func TestErrorContext(t *testing.T) {
+	for i, tt := range []struct {
+		gcfg string
+		res  interface{}
+		exp  Error
+	}{
+		{"[section]\nname=\"value", &cBasic{},
+			Error{Kind: SyntaxError}},
+		{"[section]\n=", &cBasic{},
+			Error{Kind: SyntaxError}},
+		{"[nonexistent]\nname=value", &cBasic{},
+			Error{Kind: UnknownSection, Section: "nonexistent"}},
+		{"[section \"sub\"]\nname=value", &cBasic{},
+			Error{Kind: UnknownSection, Section: "section", Subsection: "sub"}},
+		{"[sub \"A\"]\nnonexistent=value", &cSubs{},
+			Error{Kind: UnknownVariable, Section: "sub", Subsection: "A", Variable: "nonexistent", Value: "value"}},
+		{"[section]\nint=x", &cBasic{},
+			Error{Kind: ParseError, Section: "section", Variable: "int", Value: "x"}},
+		{"[section]\nint", &cBasic{},
+			Error{Kind: BlankUnsupported, Section: "section", Variable: "int"}},
+		{"[bounds-types-1]\nintR1=21", &cRegTypes{},
+			Error{Kind: BoundsError, Section: "bounds-types-1", Variable: "intR1", Value: "21"}},
+		{"[bounds-types-1]\nstringL1=a", &cRegTypes{},
+			Error{Kind: LengthError, Section: "bounds-types-1", Variable: "stringL1", Value: "a"}},
+	} {
+		err := ReadStringInto(tt.res, tt.gcfg)
+		e, ok := err.(*Error)
+		switch {
+		case !ok:
+			t.Errorf("%d fail: got error %#v, wanted *Error", i, err)
+		case e.Kind != tt.exp.Kind || e.Section != tt.exp.Section ||
+			e.Subsection != tt.exp.Subsection || e.Variable != tt.exp.Variable ||
+			e.Value != tt.exp.Value:
+			t.Errorf("%d fail: got %v %q %q %q %q, wanted %v %q %q %q %q", i,
+				e.Kind, e.Section, e.Subsection, e.Variable, e.Value, tt.exp.Kind,
+				tt.exp.Section, tt.exp.Subsection, tt.exp.Variable, tt.exp.Value)
+		case e.Pos.Line != 2 && e.Kind != UnknownSection:
+			t.Errorf("%d fail: got position %v, wanted line 2", i, e.Pos)
+		case e.Err == nil:
+			t.Errorf("%d fail: got no underlying error", i)
+		}
+	}
+}
+
+func TestErrorListUnwrap(t *testing.T) {
+	err := ReadStringInto(&cBasic{}, "[section]\nint=x\nnonexistent=1", CollectErrors())
+	errs, ok := err.(scanner.ErrorList)
+	if !ok {
+		t.Fatalf("got error %#v, wanted scanner.ErrorList", err)
+	}
+	var kinds []ErrorKind
+	for _, e := range errs.Unwrap() {
+		if e, ok := e.(*Error); ok {
+			kinds = append(kinds, e.Kind)
+		}
+	}
+	if len(kinds) != 2 || kinds[0] != ParseError || kinds[1] != UnknownVariable {
+		t.Errorf("got kinds %v, wanted [%v %v]", kinds, ParseError, UnknownVariable)
+	}
+}
@@ -1,6 +1,7 @@
 package gcfg_test
 
 import (
+	"flag"
 	"fmt"
 	"log"
 )
@@ -130,3 +131,27 @@ func ExampleReadStringInto_unicode() {
 	fmt.Println(cfg.X甲.X乙)
 	// Output: 丙
 }
+
+func ExampleOverrides() {
+	cfgStr := `; Comment line
+[server]
+port=80`
+	cfg := struct {
+		Server struct {
+			Port int
+		}
+	}{}
+	var overrides gcfg.Overrides
+	fs := flag.NewFlagSet("example", flag.ExitOnError)
+	fs.Var(&overrides, "c", "set config variable (section.variable=value)")
+	fs.Parse([]string{"-c", "server.port=8080"})
+	err := gcfg.ReadSourcesInto(&cfg, []gcfg.Source{
+		gcfg.StringSource("example.gcfg", cfgStr),
+		overrides,
+	})
+	if err != nil {
+		log.Fatalf("Failed to parse gcfg data: %s", err)
+	}
+	fmt.Println(cfg.Server.Port)
+	// Output: 8080
+}
@@ -0,0 +1,351 @@
+package gcfg
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+	"unicode"
+	"unicode/utf8"
+)
+
+// field describes a field of a config or section struct that corresponds to a
+// section or variable.
+type field struct {
+	name   string // section or variable name
+	goName string
+	path   string // Go selector within the struct
+	index  []int  // index sequence within the struct
+	typ    reflect.Type
+	tag    reflect.StructTag
+	meta   metadata
+	depth  int
+	config bool // corresponds to a section or variable
+
+	// result of checking the min and max constraints, see boundSpecErr
+	boundsOnce sync.Once
+	boundsErr  error
+}
+
+// conflict is a section or variable name, or a Go field name, shared by
+// several fields at the same depth.
+type conflict struct {
+	name  string
+	paths []string
+}
+
+// structInfo describes the fields of a config or section struct type.
+type structInfo struct {
+	fields    []*field          // in declaration order
+	byTag     map[string]*field // fields named by tag, by folded name
+	byName    map[string]*field // other fields, by folded field name
+	rest      *field            // catch-all field with the "rest" option
+	invalid   []*field          // fields left out as invalid, with meta.err set
+	conflicts []conflict
+}
+
+var structInfos = struct {
+	sync.RWMutex
+	m map[reflect.Type]*structInfo
+}{m: map[reflect.Type]*structInfo{}}
+
+// structInfoOf returns the description of struct type t, computing it on first
+// use.
+func structInfoOf(t reflect.Type) *structInfo {
+	structInfos.RLock()
+	si, ok := structInfos.m[t]
+	structInfos.RUnlock()
+	if ok {
+		return si
+	}
+	si = newStructInfo(t)
+	structInfos.Lock()
+	if csi, ok := structInfos.m[t]; ok {
+		si = csi
+	} else {
+		structInfos.m[t] = si
+	}
+	structInfos.Unlock()
+	return si
+}
+
+// newStructInfo computes the description of struct type t.
+//
+// The fields of embedded structs, and of embedded pointers to structs, are
+// promoted following the rules for Go selectors: a field is hidden by a field
+// with the same Go name at a shallower depth. The same applies to the section
+// and variable names of the remaining fields. Several fields with the same Go
+// name or the same section or variable name at the shallowest depth are
+// recorded as a conflict.
+func newStructInfo(t reflect.Type) *structInfo {
+	var all []*field
+	gonames := map[string][]*field{}
+	visited := map[reflect.Type]bool{t: true}
+	var walk func(t reflect.Type, idx []int, path string)
+	walk = func(t reflect.Type, idx []int, path string) {
+		for i := 0; i < t.NumField(); i++ {
+			sf := t.Field(i)
+			f := &field{goName: sf.Name, path: path + sf.Name, typ: sf.Type, tag: sf.Tag,
+				index: append(append([]int{}, idx...), i), depth: len(idx)}
+			f.meta = newMetadata(sf.Tag.Get("gcfg"), sf.Tag)
+			if f.meta.err == nil && f.meta.callback != "" {
+				f.meta.err = checkCallback(t, f.meta.callback)
+			}
+			if f.meta.err == nil {
+				f.meta.err = checkTextConstraints(sf.Type, f.meta)
+			}
+			gonames[sf.Name] = append(gonames[sf.Name], f)
+			if sf.Anonymous && f.meta.ident == "" {
+				et := sf.Type
+				if et.Kind() == reflect.Ptr && sf.PkgPath == "" {
+					et = et.Elem()
+				}
+				if et.Kind() == reflect.Struct {
+					if !visited[et] {
+						visited[et] = true
+						walk(et, f.index, f.path+".")
+						delete(visited, et)
+					}
+					continue
+				}
+			}
+			if sf.PkgPath != "" || f.meta.ident == "-" {
+				continue
+			}
+			f.name = fieldName(sf.Name, f.meta)
+			f.config = true
+			all = append(all, f)
+		}
+	}
+	walk(t, nil, "")
+	si := &structInfo{byTag: map[string]*field{}, byName: map[string]*field{}}
+	var visible []*field
+	tags, names := map[string][]*field{}, map[string][]*field{}
+	for _, f := range all {
+		if !dominant(f, gonames[f.goName]) {
+			si.addConflict(f.name, gonames[f.goName])
+			continue
+		}
+		if f.meta.rest {
+			if f.typ != restType {
+				f.meta.err = fmt.Errorf("field with rest option must be of type %v, not %v", restType, f.typ)
+				si.invalid = append(si.invalid, f)
+			} else if si.rest == nil || f.depth < si.rest.depth {
+				si.rest = f
+			}
+			continue
+		}
+		visible = append(visible, f)
+		if f.meta.ident != "" {
+			k := strings.ToLower(f.meta.ident)
+			tags[k] = append(tags[k], f)
+		} else {
+			k := strings.ToLower(f.goName)
+			names[k] = append(names[k], f)
+		}
+	}
+	for _, f := range visible {
+		k, m, ms := strings.ToLower(f.meta.ident), tags, si.byTag
+		if f.meta.ident == "" {
+			k, m, ms = strings.ToLower(f.goName), names, si.byName
+		}
+		if dominant(f, m[k]) {
+			ms[k] = f
+			si.fields = append(si.fields, f)
+		} else {
+			si.addConflict(f.name, m[k])
+		}
+	}
+	return si
+}
+
+// addConflict records a conflict for name among the fields fs at the
+// shallowest depth, unless already recorded or only hiding each other fields
+// not corresponding to sections or variables.
+func (si *structInfo) addConflict(name string, fs []*field) {
+	depth := -1
+	for _, f := range fs {
+		if depth < 0 || f.depth < depth {
+			depth = f.depth
+		}
+	}
+	var paths []string
+	config := false
+	for _, f := range fs {
+		if f.depth == depth {
+			paths = append(paths, f.path)
+			config = config || f.config
+		}
+	}
+	if len(paths) < 2 || !config {
+		return
+	}
+	for _, c := range si.conflicts {
+		if c.name == name || reflect.DeepEqual(c.paths, paths) {
+			return
+		}
+	}
+	si.conflicts = append(si.conflicts, conflict{name, paths})
+}
+
+// errors returns the conflicts and invalid fields of si as errors for section
+// sect, or for the sections of the config if sect is empty.
+func (si *structInfo) errors(sect string) []*Error {
+	var errs []*Error
+	add := func(name string, err error) {
+		if sect == "" {
+			errs = append(errs, newError(TypeError, name, "", "", err))
+		} else {
+			errs = append(errs, newError(TypeError, sect, "", name, err))
+		}
+	}
+	for _, c := range si.conflicts {
+		add(c.name, fmt.Errorf("ambiguous fields %s", strings.Join(c.paths, ", ")))
+	}
+	for _, f := range si.invalid {
+		add(f.name, f.meta.err)
+	}
+	return errs
+}
+
+// checkType reports the errors in the type of config, which must be a pointer
+// to a struct, including the min and max constraints that cannot be parsed
+// with the setters of the read.
+func (rs *readState) checkType(config interface{}) {
+	vc := reflect.ValueOf(config)
+	if vc.Kind() != reflect.Ptr || vc.Elem().Kind() != reflect.Struct {
+		panic(fmt.Errorf("config must be a pointer to a struct"))
+	}
+	for _, e := range typeErrors(vc.Elem().Type()) {
+		rs.report(e)
+	}
+	for _, e := range rs.boundErrors(vc.Elem().Type()) {
+		rs.report(e)
+	}
+}
+
+// sectionType returns the struct type of a section field of type t, or nil if
+// t is not a section type.
+func sectionType(t reflect.Type) reflect.Type {
+	switch {
+	case t.Kind() == reflect.Map && t.Elem().Kind() == reflect.Ptr:
+		t = t.Elem().Elem()
+	case t.Kind() == reflect.Ptr:
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct {
+		return nil
+	}
+	return t
+}
+
+// typeErrors returns the errors in the config struct type t and its section
+// struct types, including invalid constraints in their struct tags.
+func typeErrors(t reflect.Type) []*Error {
+	si := structInfoOf(t)
+	errs := si.errors("")
+	for _, f := range si.fields {
+		if f.meta.err != nil {
+			errs = append(errs, newError(TypeError, f.name, "", "", f.meta.err))
+		}
+		st := sectionType(f.typ)
+		if st == nil {
+			continue
+		}
+		ssi := structInfoOf(st)
+		errs = append(errs, ssi.errors(f.name)...)
+		for _, vf := range ssi.fields {
+			if vf.meta.err != nil {
+				errs = append(errs, newError(TypeError, f.name, "", vf.name, vf.meta.err))
+			}
+		}
+	}
+	return errs
+}
+
+// dominant reports whether f is the only one among fs at the shallowest depth.
+func dominant(f *field, fs []*field) bool {
+	for _, g := range fs {
+		if g != f && g.depth <= f.depth {
+			return false
+		}
+	}
+	return true
+}
+
+// fieldName returns the section or variable name corresponding to the field
+// with Go name n and metadata t.
+func fieldName(n string, t metadata) string {
+	if t.ident != "" {
+		return t.ident
+	}
+	if r, _ := utf8.DecodeRuneInString(n[1:]); n[0] == 'X' &&
+		unicode.IsLetter(r) && !unicode.IsLower(r) && !unicode.IsUpper(r) {
+		n = n[1:]
+	}
+	return strings.ToLower(strings.Replace(n, "_", "-", -1))
+}
+
+// lookup returns the field for the section or variable name, or nil if there
+// is none. Names given by tags take precedence over field names; names match
+// ignoring case, and '-' in name matches '_' in field names.
+func (si *structInfo) lookup(name string) *field {
+	if f := si.byTag[strings.ToLower(name)]; f != nil {
+		return f
+	}
+	var n string
+	r0, _ := utf8.DecodeRuneInString(name)
+	if unicode.IsLetter(r0) && !unicode.IsLower(r0) && !unicode.IsUpper(r0) {
+		n = "X"
+	}
+	n += strings.Replace(name, "-", "_", -1)
+	return si.byName[strings.ToLower(n)]
+}
+
+// fieldFold returns the field of struct v for the section or variable name,
+// and its description. If alloc is not nil, nil embedded pointers on the way
+// to the field are allocated, as by fieldByIndex; otherwise the returned Value
+// is invalid if the field is reached through one. It is also invalid if there
+// is no such field.
+func fieldFold(v reflect.Value, name string, alloc func(prefix []int)) (reflect.Value, *field) {
+	f := structInfoOf(v.Type()).lookup(name)
+	if f == nil {
+		return reflect.Value{}, nil
+	}
+	return fieldByIndex(v, f.index, alloc), f
+}
+
+// fieldByIndex returns the field of struct v with index sequence index. If
+// alloc is not nil, nil embedded pointers on the way to the field are
+// allocated, and alloc is called with the index sequence of each pointer
+// allocated; otherwise the zero Value is returned if the field is reached
+// through one.
+func fieldByIndex(v reflect.Value, index []int, alloc func(prefix []int)) reflect.Value {
+	for i, x := range index {
+		if i > 0 && v.Kind() == reflect.Ptr {
+			if v.IsNil() {
+				if alloc == nil {
+					return reflect.Value{}
+				}
+				v.Set(reflect.New(v.Type().Elem()))
+				alloc(index[:i])
+			}
+			v = v.Elem()
+		}
+		v = v.Field(x)
+	}
+	return v
+}
+
+// hasPrefix reports whether the index sequence index begins with prefix.
+func hasPrefix(index, prefix []int) bool {
+	if len(index) < len(prefix) {
+		return false
+	}
+	for i := range prefix {
+		if index[i] != prefix[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,180 @@
+package gcfg
+
+import (
+	"bytes"
+	"io/ioutil"
+	"reflect"
+	"sync"
+	"testing"
+)
+
+type cFieldsInner struct {
+	Name  string
+	Outer string
+	Deep  string `gcfg:"tagged"`
+}
+type cFieldsOther struct {
+	Name string
+}
+type cFieldsS1 struct {
+	cFieldsInner
+	cFieldsOther
+	Outer  string
+	Tagged string
+}
+
+func TestStructInfoLookup(t *testing.T) {
+	typ := reflect.TypeOf(cFieldsS1{})
+	for i, tt := range []struct {
+		name string
+		idx  []int
+	}{
+		// shallower fields hide embedded ones
+		{"outer", []int{2}},
+		// names given by tags take precedence over field names
+		{"tagged", []int{0, 2}},
+		// fields with the same name at the same depth hide each other
+		{"name", nil},
+		{"deep", nil},
+	} {
+		var idx []int
+		if f := structInfoOf(typ).lookup(tt.name); f != nil {
+			idx = f.index
+		}
+		if !reflect.DeepEqual(idx, tt.idx) {
+			t.Errorf("%d fail: %q got index %v, wanted %v", i, tt.name, idx, tt.idx)
+		}
+	}
+}
+
+func TestStructInfoConcurrent(t *testing.T) {
+	var wg sync.WaitGroup
+	src := benchConfig(10)
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			var cfg cBench
+			if err := ReadStringInto(&cfg, string(src)); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+type CEmbedCommon struct {
+	Host   string
+	Port   int `gcfg:",cb=Called"`
+	called bool
+}
+
+func (c *CEmbedCommon) Called() { c.called = true }
+
+type cEmbedS1 struct {
+	*cEmbedCommon
+	Name string
+}
+type cEmbedPtr struct {
+	*CEmbedCommon
+	Name string
+}
+type cEmbedCommon CEmbedCommon
+
+type cEmbedSections struct {
+	Main cEmbedMainS
+}
+type cEmbedMainS struct {
+	Name string
+}
+type cEmbed struct {
+	*cEmbedSections
+	*CEmbedSections
+	Section cEmbedPtr
+	Sub     map[string]*cEmbedPtr
+}
+type CEmbedSections struct {
+	Extra cEmbedMainS
+}
+
+func TestEmbedded(t *testing.T) {
+	res := &cEmbed{}
+	err := ReadStringInto(res, "[extra]\nname=e\n[section]\nhost=h\nname=n\n"+
+		"[sub \"a\"]\nname=a\n[sub \"b\"]\nport=1")
+	if err != nil {
+		t.Fatalf("got error %v, wanted ok", err)
+	}
+	// unexported embedded pointers cannot be allocated
+	if res.cEmbedSections != nil {
+		t.Errorf("got %#v, wanted nil", res.cEmbedSections)
+	}
+	// exported embedded pointers are allocated as needed
+	if res.CEmbedSections == nil || res.Extra.Name != "e" {
+		t.Errorf("got %#v, wanted extra name %q", res.CEmbedSections, "e")
+	}
+	if res.Section.CEmbedCommon == nil || res.Section.Host != "h" || res.Section.Name != "n" {
+		t.Errorf("got %#v, wanted host and name set", res.Section)
+	}
+	if res.Sub["a"].CEmbedCommon != nil {
+		t.Errorf("got %#v, wanted nil", res.Sub["a"].CEmbedCommon)
+	}
+	// callbacks are called on the struct declaring the field
+	if c := res.Sub["b"].CEmbedCommon; c == nil || c.Port != 1 || !c.called {
+		t.Errorf("got %#v, wanted port set and callback called", c)
+	}
+	err = ReadStringInto(&struct{ Main cEmbedS1 }{}, "[main]\nhost=h")
+	if e, ok := err.(*Error); !ok || e.Kind != UnknownVariable {
+		t.Errorf("got error %#v, wanted unknown variable", err)
+	}
+}
+
+func TestEmbeddedConflict(t *testing.T) {
+	for i, tt := range []struct {
+		config interface{}
+		sect   string
+		name   string
+	}{
+		{&struct{ Section cFieldsS1 }{}, "section", "name"},
+		{&struct {
+			cFieldsInner
+			CFieldsInner
+		}{}, "outer", ""},
+	} {
+		err := ReadStringInto(tt.config, "")
+		if e, ok := err.(*Error); !ok || e.Kind != TypeError ||
+			e.Section != tt.sect || e.Variable != tt.name {
+			t.Errorf("%d fail: got error %#v, wanted type error for %q %q",
+				i, err, tt.sect, tt.name)
+			continue
+		}
+		if err := Write(tt.config, ioutil.Discard); err == nil {
+			t.Errorf("%d fail: got ok from Write, wanted error", i)
+		}
+	}
+}
+
+type CFieldsInner struct {
+	Outer string
+}
+
+func TestWriteEmbedded(t *testing.T) {
+	res := &cEmbed{}
+	res.CEmbedSections = &CEmbedSections{Extra: cEmbedMainS{Name: "e"}}
+	res.Section.CEmbedCommon = &CEmbedCommon{Host: "h"}
+	res.Section.Name = "n"
+	var buf bytes.Buffer
+	if err := Write(res, &buf); err != nil {
+		t.Fatal(err)
+	}
+	exp := "[extra]\nname = e\n\n[section]\nhost = h\nname = n\n\n"
+	if buf.String() != exp {
+		t.Errorf("got %q, wanted %q", buf.String(), exp)
+	}
+	got := &cEmbed{}
+	if err := ReadStringInto(got, buf.String()); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, res) {
+		t.Errorf("got %#v, wanted %#v", got, res)
+	}
+}
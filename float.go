@@ -0,0 +1,184 @@
+package gcfg
+
+import (
+	"fmt"
+	"math"
+	"math/big"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// stripNumber returns the number s without the underscores separating its
+// digits, and without the '%' suffix if percent is set; the latter is
+// reported by pct.
+func stripNumber(s string, percent bool) (n string, pct bool, err error) {
+	if percent && strings.HasSuffix(s, "%") {
+		s, pct = strings.TrimSpace(s[:len(s)-1]), true
+	}
+	if strings.IndexByte(s, '_') < 0 {
+		return s, pct, nil
+	}
+	isDigit := func(i int) bool { return 0 <= i && i < len(s) && '0' <= s[i] && s[i] <= '9' }
+	b := make([]byte, 0, len(s))
+	for i := 0; i < len(s); i++ {
+		if s[i] != '_' {
+			b = append(b, s[i])
+		} else if !isDigit(i-1) || !isDigit(i+1) {
+			return "", false, fmt.Errorf("invalid number %q: '_' must separate digits", s)
+		}
+	}
+	return string(b), pct, nil
+}
+
+// parseFloat parses val as a floating-point number of the given bit size. A
+// percent value is converted exactly before rounding.
+func parseFloat(val string, bitSize int, percent bool) (float64, error) {
+	s, pct, err := stripNumber(val, percent)
+	if err != nil {
+		return 0, err
+	}
+	if !pct {
+		return parseFloatPart(s, bitSize)
+	}
+	r, err := parseRat(s, true)
+	if err != nil {
+		return 0, err
+	}
+	var f float64
+	if bitSize == 32 {
+		f32, _ := r.Float32()
+		f = float64(f32)
+	} else {
+		f, _ = r.Float64()
+	}
+	if math.IsInf(f, 0) {
+		return 0, fmt.Errorf("invalid number %q: value out of range", val)
+	}
+	return f, nil
+}
+
+// parseFloatPart parses s, stripped of underscores, as a floating-point
+// number of the given bit size. NaN is rejected, as it would pass any bounds.
+func parseFloatPart(s string, bitSize int) (float64, error) {
+	f, err := strconv.ParseFloat(s, bitSize)
+	if err == nil && math.IsNaN(f) {
+		return 0, fmt.Errorf("invalid number %q: NaN not allowed", s)
+	}
+	return f, err
+}
+
+// parseRat parses s, stripped of underscores and '%', as a fraction "a/b" or
+// a decimal number with optional exponent, dividing it by 100 if pct is set.
+func parseRat(s string, pct bool) (*big.Rat, error) {
+	r, ok := new(big.Rat).SetString(s)
+	if !ok {
+		return nil, fmt.Errorf("invalid number %q", s)
+	}
+	if pct {
+		r.Quo(r, big.NewRat(100, 1))
+	}
+	return r, nil
+}
+
+// parseComplex parses val as a complex number of the given bit size, written
+// as a real part, an imaginary part ending in 'i', or both, optionally
+// enclosed in parentheses.
+func parseComplex(val string, bitSize int) (complex128, error) {
+	s, _, err := stripNumber(val, false)
+	if err != nil {
+		return 0, err
+	}
+	if strings.HasPrefix(s, "(") && strings.HasSuffix(s, ")") {
+		s = s[1 : len(s)-1]
+	}
+	errSyntax := fmt.Errorf("invalid complex number %q", val)
+	if !strings.HasSuffix(s, "i") {
+		re, err := parseFloatPart(s, bitSize/2)
+		if err != nil {
+			return 0, errSyntax
+		}
+		return complex(re, 0), nil
+	}
+	s = s[:len(s)-1]
+	// the imaginary part starts at the last sign not in an exponent
+	i := len(s) - 1
+	for ; i > 0; i-- {
+		if c, p := s[i], s[i-1]; (c == '+' || c == '-') &&
+			p != 'e' && p != 'E' && p != 'p' && p != 'P' {
+			break
+		}
+	}
+	if i < 0 {
+		i = 0
+	}
+	var re, im float64
+	if i > 0 {
+		if re, err = parseFloatPart(s[:i], bitSize/2); err != nil {
+			return 0, errSyntax
+		}
+	}
+	switch s[i:] {
+	case "", "+":
+		im = 1
+	case "-":
+		im = -1
+	default:
+		if im, err = parseFloatPart(s[i:], bitSize/2); err != nil {
+			return 0, errSyntax
+		}
+	}
+	return complex(re, im), nil
+}
+
+func floatSetter(d interface{}, blank bool, val string, t metadata) error {
+	if blank {
+		return errBlankUnsupported
+	}
+	v := reflect.ValueOf(d).Elem()
+	f, err := parseFloat(val, v.Type().Bits(), t.percent)
+	if err != nil {
+		return err
+	}
+	v.SetFloat(f)
+	return nil
+}
+
+func complexSetter(d interface{}, blank bool, val string, t metadata) error {
+	if blank {
+		return errBlankUnsupported
+	}
+	v := reflect.ValueOf(d).Elem()
+	c, err := parseComplex(val, v.Type().Bits())
+	if err != nil {
+		return err
+	}
+	v.SetComplex(c)
+	return nil
+}
+
+func ratSetter(d interface{}, blank bool, val string, t metadata) error {
+	if blank {
+		return errBlankUnsupported
+	}
+	s, pct, err := stripNumber(val, t.percent)
+	if err != nil {
+		return err
+	}
+	r, err := parseRat(s, pct)
+	if err != nil {
+		return err
+	}
+	d.(*big.Rat).Set(r)
+	return nil
+}
+
+func ratFormatter(s interface{}, t metadata) (string, error) {
+	return s.(*big.Rat).RatString(), nil
+}
+
+// bigComparer orders values of the types in math/big with their Cmp method.
+func bigComparer(a, b interface{}) int {
+	cmp := reflect.ValueOf(a).MethodByName("Cmp")
+	return int(cmp.Call([]reflect.Value{reflect.ValueOf(b)})[0].Int())
+}
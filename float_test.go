@@ -0,0 +1,112 @@
+package gcfg
+
+import (
+	"bytes"
+	"math"
+	"math/big"
+	"testing"
+)
+
+type cFloatSect struct {
+	F32   float32
+	F64   float64
+	Pct   float64 `gcfg:",percent"`
+	Pct32 float32 `gcfg:",percent"`
+	C64   complex64
+	C128  complex128
+	Rat   big.Rat
+	RatP  *big.Rat `gcfg:",percent"`
+	Float big.Float
+	FB    float64   `min:"0" max:"50%" gcfg:",percent"`
+	RB    big.Rat   `min:"1/3" max:"2/3"`
+	FltB  big.Float `min:"0.1"`
+	IntB  big.Int   `max:"100"`
+}
+
+type cFloat struct {
+	Section cFloatSect
+}
+
+func TestFloat(t *testing.T) {
+	for i, tt := range []struct {
+		in    string
+		check func(s *cFloatSect) bool
+		kind  ErrorKind
+	}{
+		{"f32=1.5e3", func(s *cFloatSect) bool { return s.F32 == 1500 }, -1},
+		{"f64=1_000.25", func(s *cFloatSect) bool { return s.F64 == 1000.25 }, -1},
+		{"f64=-2.5E-3", func(s *cFloatSect) bool { return s.F64 == -0.0025 }, -1},
+		{"f64=1__0", nil, ParseError},
+		{"f64=_1", nil, ParseError},
+		{"f64=1e", nil, ParseError},
+		{"f64=nan", nil, ParseError},
+		{"f64=inf", func(s *cFloatSect) bool { return math.IsInf(s.F64, 1) }, -1},
+		{"f64=75%", nil, ParseError},
+		{"f32=1e39", nil, ParseError},
+		{"pct=75%", func(s *cFloatSect) bool { return s.Pct == 0.75 }, -1},
+		{"pct=7%", func(s *cFloatSect) bool { return s.Pct == 0.07 }, -1},
+		{"pct=0.5", func(s *cFloatSect) bool { return s.Pct == 0.5 }, -1},
+		{"pct32=1e41%", nil, ParseError},
+		{"pct32=1e40%", func(s *cFloatSect) bool { return s.Pct32 == 1e38 }, -1},
+		{"pct=1e400%", nil, ParseError},
+		{"c64=1+2i", func(s *cFloatSect) bool { return s.C64 == 1+2i }, -1},
+		{"c128=(1.5e2-0.5i)", func(s *cFloatSect) bool { return s.C128 == 150-0.5i }, -1},
+		{"c128=-i", func(s *cFloatSect) bool { return s.C128 == -1i }, -1},
+		{"c128=3", func(s *cFloatSect) bool { return s.C128 == 3 }, -1},
+		{"c128=1e+2i", func(s *cFloatSect) bool { return s.C128 == 100i }, -1},
+		{"c128=1+2j", nil, ParseError},
+		{"c128=nan", nil, ParseError},
+		{"c64=1+nani", nil, ParseError},
+		{"c64=1e39+1i", nil, ParseError},
+		{"rat=3/4", func(s *cFloatSect) bool { return s.Rat.Cmp(big.NewRat(3, 4)) == 0 }, -1},
+		{"rat=1_250e-3", func(s *cFloatSect) bool { return s.Rat.Cmp(big.NewRat(5, 4)) == 0 }, -1},
+		{"ratp=12.5%", func(s *cFloatSect) bool { return s.RatP.Cmp(big.NewRat(1, 8)) == 0 }, -1},
+		{"float=0.1", func(s *cFloatSect) bool { return s.Float.String() == "0.1" }, -1},
+		{"float=x", nil, ParseError},
+		{"fb=50%", func(s *cFloatSect) bool { return s.FB == 0.5 }, -1},
+		{"fb=0.51", nil, BoundsError},
+		{"fb=-1e-9", nil, BoundsError},
+		{"fb=NaN", nil, ParseError},
+		{"rb=1/3", func(s *cFloatSect) bool { return s.RB.Cmp(big.NewRat(1, 3)) == 0 }, -1},
+		{"rb=0.3333333333", nil, BoundsError},
+		{"rb=0.6666666667", nil, BoundsError},
+		{"fltb=0.1", func(s *cFloatSect) bool { return s.FltB.String() == "0.1" }, -1},
+		{"fltb=0.0999999999", nil, BoundsError},
+		{"intb=100", func(s *cFloatSect) bool { return s.IntB.Int64() == 100 }, -1},
+		{"intb=101", nil, BoundsError},
+	} {
+		cfg := &cFloat{}
+		err := ReadStringInto(cfg, "[section]\n"+tt.in)
+		switch {
+		case tt.kind < 0 && err != nil:
+			t.Errorf("%d: %s: unexpected error: %v", i, tt.in, err)
+		case tt.kind < 0 && !tt.check(&cfg.Section):
+			t.Errorf("%d: %s: got %+v", i, tt.in, cfg.Section)
+		case tt.kind >= 0 && err == nil:
+			t.Errorf("%d: %s: no error", i, tt.in)
+		case tt.kind >= 0 && err.(*Error).Kind != tt.kind:
+			t.Errorf("%d: %s: got error %v, want %v", i, tt.in, err, tt.kind)
+		}
+	}
+}
+
+func TestWriteFloat(t *testing.T) {
+	cfg := &cFloat{}
+	in := "[section]\nf64=0.1\nc128=1-2i\nrat=1/3\nratp=50%\nfloat=1e-20\nrb=1/2\nfltb=0.2"
+	if err := ReadStringInto(cfg, in); err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if err := Write(cfg, &buf); err != nil {
+		t.Fatal(err)
+	}
+	res := &cFloat{}
+	if err := ReadStringInto(res, buf.String()); err != nil {
+		t.Fatalf("%v\n%s", err, buf.String())
+	}
+	s, r := &cfg.Section, &res.Section
+	if r.F64 != s.F64 || r.C128 != s.C128 || r.Rat.Cmp(&s.Rat) != 0 ||
+		r.RatP.Cmp(s.RatP) != 0 || r.Float.Cmp(&s.Float) != 0 || r.FltB.Cmp(&s.FltB) != 0 {
+		t.Errorf("read back %+v, want %+v\n%s", r, s, buf.String())
+	}
+}
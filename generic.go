@@ -0,0 +1,77 @@
+//go:build go1.18
+// +build go1.18
+
+package gcfg
+
+import (
+	"reflect"
+)
+
+// typeOf returns the type T, including for interface types.
+func typeOf[T any]() reflect.Type {
+	return reflect.TypeOf((*T)(nil)).Elem()
+}
+
+func parserSetter[T any](parse func(blank bool, val string) (T, error)) setter {
+	return func(d interface{}, blank bool, val string, t metadata) error {
+		v, err := parse(blank, val)
+		if err != nil {
+			return err
+		}
+		*d.(*T) = v
+		return nil
+	}
+}
+
+func formatterOf[T any](format func(v T) (string, error)) formatter {
+	return func(s interface{}, t metadata) (string, error) {
+		return format(*s.(*T))
+	}
+}
+
+// RegisterParser registers parse in DefaultRegistry for values of type T, as
+// RegisterTypeParser does for the type, but without converting the value
+// parsed.
+func RegisterParser[T any](parse func(blank bool, val string) (T, error)) {
+	RegisterParserIn(DefaultRegistry, parse)
+}
+
+// RegisterParserIn registers parse in r for values of type T.
+func RegisterParserIn[T any](r *Registry, parse func(blank bool, val string) (T, error)) {
+	r.register(typeOf[T](), parserSetter(parse))
+}
+
+// RegisterOrder registers compare in DefaultRegistry for ordering values of
+// type T parsed with a parser registered in DefaultRegistry, for checking the
+// min and max bounds of the variable. compare returns a negative number, zero
+// or a positive number if a is less than, equal to or greater than b.
+func RegisterOrder[T any](compare func(a, b T) int) {
+	RegisterOrderIn(DefaultRegistry, compare)
+}
+
+// RegisterOrderIn registers compare in r for ordering values of type T parsed
+// with a parser registered in r, as RegisterOrder does.
+func RegisterOrderIn[T any](r *Registry, compare func(a, b T) int) {
+	r.registerOrder(typeOf[T](), func(a, b interface{}) int {
+		return compare(*a.(*T), *b.(*T))
+	})
+}
+
+// UseParser makes the read parse values of type T with parse, as
+// UseTypeParser does for the type.
+func UseParser[T any](parse func(blank bool, val string) (T, error)) ReadOption {
+	return useSetter(typeOf[T](), parserSetter(parse))
+}
+
+// RegisterFormatter registers format for writing values of type T, as
+// RegisterTypeFormatter does for the type. An error returned by format fails
+// the write.
+func RegisterFormatter[T any](format func(v T) (string, error)) {
+	registerFormatter(typeOf[T](), formatterOf(format))
+}
+
+// UseFormatter makes the write format values of type T with format, as
+// UseTypeFormatter does for the type.
+func UseFormatter[T any](format func(v T) (string, error)) WriteOption {
+	return useFormatter(typeOf[T](), formatterOf(format))
+}
@@ -0,0 +1,122 @@
+//go:build go1.18
+// +build go1.18
+
+package gcfg
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type cGenericVersion struct {
+	Major, Minor int
+}
+
+type cGenericLabels map[string]string
+
+type cGeneric struct {
+	Section struct {
+		Version cGenericVersion `min:"1.2" max:"2.0"`
+		Labels  cGenericLabels
+		Named   fmt.Stringer
+	}
+}
+
+type cGenericName string
+
+func (n cGenericName) String() string { return string(n) }
+
+func parseVersion(blank bool, val string) (cGenericVersion, error) {
+	var v cGenericVersion
+	_, err := fmt.Sscanf(val, "%d.%d", &v.Major, &v.Minor)
+	return v, err
+}
+
+func compareVersion(a, b cGenericVersion) int {
+	if a.Major != b.Major {
+		return a.Major - b.Major
+	}
+	return a.Minor - b.Minor
+}
+
+func parseLabels(blank bool, val string) (cGenericLabels, error) {
+	l := cGenericLabels{}
+	for _, kv := range strings.Split(val, ",") {
+		i := strings.Index(kv, ":")
+		if i < 0 {
+			return nil, fmt.Errorf("missing ':' in %q", kv)
+		}
+		l[kv[:i]] = kv[i+1:]
+	}
+	return l, nil
+}
+
+func TestGenericParser(t *testing.T) {
+	r := NewRegistry()
+	RegisterParserIn(r, parseVersion)
+	RegisterOrderIn(r, compareVersion)
+	RegisterParserIn(r, parseLabels)
+	RegisterParserIn(r, func(blank bool, val string) (fmt.Stringer, error) {
+		return cGenericName(val), nil
+	})
+	for i, tt := range []struct {
+		in   string
+		kind ErrorKind
+		ok   bool
+	}{
+		{"[section]\nversion=1.10\nlabels=a:1,b:2\nnamed=n", 0, true},
+		{"[section]\nversion=1.1", BoundsError, false},
+		{"[section]\nversion=2.1", BoundsError, false},
+		{"[section]\nversion=x", ParseError, false},
+		{"[section]\nlabels=a", ParseError, false},
+	} {
+		cfg := &cGeneric{}
+		err := ReadStringInto(cfg, tt.in, UseRegistry(r))
+		if tt.ok {
+			exp := &cGeneric{}
+			exp.Section.Version = cGenericVersion{1, 10}
+			exp.Section.Labels = cGenericLabels{"a": "1", "b": "2"}
+			exp.Section.Named = cGenericName("n")
+			if err != nil || !reflect.DeepEqual(cfg, exp) {
+				t.Errorf("%d: got %+v, %v", i, cfg.Section, err)
+			}
+			continue
+		}
+		if e, ok := err.(*Error); !ok || e.Kind != tt.kind {
+			t.Errorf("%d: got error %v, want kind %v", i, err, tt.kind)
+		}
+	}
+	// parsers given as options
+	cfg := &cGeneric{}
+	err := ReadStringInto(cfg, "[section]\nlabels=a:1", UseParser(parseLabels))
+	if err != nil || cfg.Section.Labels["a"] != "1" {
+		t.Errorf("UseParser: got %+v, %v", cfg.Section, err)
+	}
+}
+
+func TestGenericFormatter(t *testing.T) {
+	cfg := &cGeneric{}
+	cfg.Section.Version = cGenericVersion{1, 2}
+	format := func(v cGenericVersion) (string, error) {
+		if v.Major < 0 {
+			return "", errors.New("negative version")
+		}
+		return fmt.Sprintf("%d.%d", v.Major, v.Minor), nil
+	}
+	var buf bytes.Buffer
+	if err := Write(cfg, &buf, UseFormatter(format)); err != nil {
+		t.Fatal(err)
+	}
+	if exp := "[section]\nversion = 1.2\n\n"; buf.String() != exp {
+		t.Errorf("got %q, want %q", buf.String(), exp)
+	}
+	cfg.Section.Version.Major = -1
+	if err := Write(cfg, &buf, UseFormatter(format)); err == nil ||
+		!strings.Contains(err.Error(), "negative version") {
+		t.Errorf("got error %v", err)
+	}
+}
@@ -5,3 +5,7 @@ package gcfg
 type textUnmarshaler interface {
 	UnmarshalText(text []byte) error
 }
+
+type textMarshaler interface {
+	MarshalText() (text []byte, err error)
+}
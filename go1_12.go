@@ -0,0 +1,12 @@
+// +build !go1.13
+
+package gcfg
+
+import (
+	"fmt"
+)
+
+// valueNumError returns err for value n of a multi-valued variable.
+func valueNumError(n int, err error) error {
+	return fmt.Errorf("value %d: %v", n, err)
+}
@@ -0,0 +1,14 @@
+//go:build go1.13
+// +build go1.13
+
+package gcfg
+
+import (
+	"fmt"
+)
+
+// valueNumError returns err for value n of a multi-valued variable, wrapping
+// it so that errors.Is and errors.As reach the cause.
+func valueNumError(n int, err error) error {
+	return fmt.Errorf("value %d: %w", n, err)
+}
@@ -0,0 +1,41 @@
+//go:build go1.13
+// +build go1.13
+
+package gcfg
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+type errMulti struct{ val string }
+
+func (e *errMulti) Error() string { return "bad value " + e.val }
+
+type cMultiErr struct {
+	Section struct {
+		Values []multiErrValue
+	}
+}
+
+type multiErrValue string
+
+func (v *multiErrValue) UnmarshalText(text []byte) error {
+	if string(text) == "bad" {
+		return &errMulti{string(text)}
+	}
+	*v = multiErrValue(text)
+	return nil
+}
+
+func TestMultiValueErrorUnwrap(t *testing.T) {
+	err := ReadStringInto(&cMultiErr{}, "[section]\nvalues=a\nvalues=bad")
+	var target *errMulti
+	if !errors.As(err, &target) || target.val != "bad" {
+		t.Errorf("got error %v, wanted it to wrap *errMulti", err)
+	}
+	if err == nil || !strings.Contains(err.Error(), "value 2: bad value bad") {
+		t.Errorf("got error %v, wanted value 2 in message", err)
+	}
+}
@@ -7,3 +7,5 @@ import (
 )
 
 type textUnmarshaler encoding.TextUnmarshaler
+
+type textMarshaler encoding.TextMarshaler
@@ -0,0 +1,41 @@
+//go:build go1.5
+// +build go1.5
+
+package gcfg
+
+import (
+	"fmt"
+	"math/big"
+	"reflect"
+)
+
+func init() {
+	t := reflect.TypeOf(big.Float{})
+	typeSetters[t] = bigFloatSetter
+	typeComparers[t] = bigComparer
+	typeFormatterFuncs[t] = bigFloatFormatter
+}
+
+func bigFloatSetter(d interface{}, blank bool, val string, t metadata) error {
+	if blank {
+		return errBlankUnsupported
+	}
+	s, pct, err := stripNumber(val, t.percent)
+	if err != nil {
+		return err
+	}
+	f := d.(*big.Float)
+	if _, ok := f.SetString(s); !ok {
+		return fmt.Errorf("invalid number %q", s)
+	}
+	if pct {
+		f.Quo(f, big.NewFloat(100))
+	}
+	return nil
+}
+
+// bigFloatFormatter formats a big.Float with the digits needed to read it
+// back at its precision.
+func bigFloatFormatter(s interface{}, t metadata) (string, error) {
+	return s.(*big.Float).Text('g', -1), nil
+}
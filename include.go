@@ -0,0 +1,136 @@
+package gcfg
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+import (
+	"github.com/baobabus/gcfg/token"
+)
+
+// maxIncludeDepth limits the nesting of included files.
+const maxIncludeDepth = 10
+
+// AllowIncludes enables the include directives:
+//
+//	[include]
+//	path = other.gcfg
+//
+//	[includeIf "env:DEPLOY=prod*"]
+//	path = prod.gcfg
+//
+// Each path variable reads the named file at that point, as if its contents
+// appeared in place of the directive. Relative paths are resolved against the
+// directory of the including file, or against the current directory for
+// sources without a file name.
+//
+// The includeIf section reads its path variables only if its condition, given
+// as the subsection name, holds. Conditions are:
+//
+//	env:NAME          environment variable NAME is set and not empty
+//	env:NAME=pattern  value of environment variable NAME matches pattern
+//	hostname:pattern  host name matches pattern
+//
+// where pattern is in the syntax of path.Match.
+//
+// Including a file that is already being read, nesting includes more than 10
+// levels deep, or including a file that cannot be read is an error.
+func AllowIncludes() ReadOption {
+	return func(rs *readState) { rs.includes = true }
+}
+
+func isIncludeSection(sect string) bool {
+	return strings.EqualFold(sect, "include") || strings.EqualFold(sect, "includeIf")
+}
+
+// include processes the variable name in an include or includeIf section at
+// pos. Relative paths are resolved against the directory of pos.Filename.
+func (rs *readState) include(config interface{}, sect, sub, name string,
+	pos token.Position, blank bool, val string) {
+	errfn := func(k ErrorKind, err error) {
+		e := newError(k, sect, sub, name, err)
+		e.Pos, e.Value = pos, val
+		rs.report(e)
+	}
+	isIf := strings.EqualFold(sect, "includeIf")
+	switch {
+	case !isIf && sub != "":
+		errfn(UnknownSection, errInvalidSubsection)
+		return
+	case isIf && sub == "":
+		errfn(UnknownSection, fmt.Errorf("missing include condition"))
+		return
+	case !strings.EqualFold(name, "path"):
+		errfn(UnknownVariable, errInvalidVariable)
+		return
+	case blank || val == "":
+		errfn(IncludeError, fmt.Errorf("empty include path"))
+		return
+	}
+	if isIf {
+		ok, err := includeCond(sub)
+		if err != nil {
+			errfn(IncludeError, err)
+			return
+		}
+		if !ok {
+			return
+		}
+	}
+	filename := val
+	if !filepath.IsAbs(filename) {
+		filename = filepath.Join(filepath.Dir(pos.Filename), filename)
+	}
+	if abs, err := filepath.Abs(filename); err == nil {
+		for _, f := range rs.reading {
+			if f == abs {
+				errfn(IncludeError, fmt.Errorf("include cycle: %s", filename))
+				return
+			}
+		}
+	}
+	if len(rs.reading) > maxIncludeDepth {
+		errfn(IncludeError, fmt.Errorf("includes nested too deeply"))
+		return
+	}
+	src, err := rs.readFile(filename)
+	if err != nil {
+		errfn(IncludeError, err)
+		return
+	}
+	rs.readSource(config, filename, src)
+}
+
+// includeCond reports whether the includeIf condition cond holds.
+func includeCond(cond string) (bool, error) {
+	i := strings.Index(cond, ":")
+	if i < 0 {
+		return false, fmt.Errorf("invalid include condition %q", cond)
+	}
+	var val, pattern string
+	switch key := cond[i+1:]; cond[:i] {
+	case "env":
+		if j := strings.Index(key, "="); j >= 0 {
+			val, pattern = os.Getenv(key[:j]), key[j+1:]
+		} else {
+			return os.Getenv(key) != "", nil
+		}
+	case "hostname":
+		h, err := os.Hostname()
+		if err != nil {
+			return false, err
+		}
+		val, pattern = h, key
+	default:
+		return false, fmt.Errorf("unknown include condition %q", cond)
+	}
+	ok, err := path.Match(pattern, val)
+	if err != nil {
+		return false, fmt.Errorf("invalid include condition %q: %v", cond, err)
+	}
+	return ok, nil
+}
@@ -0,0 +1,77 @@
+package gcfg
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestIncludes(t *testing.T) {
+	res := &cSources{}
+	err := ReadFileInto(res, "testdata/include/main.gcfg", AllowIncludes())
+	exp := &cSources{Section: cSourcesS1{Name: "a", Int: 2, Multi: []string{"a", "main"}}}
+	if err != nil {
+		t.Errorf("got error %v, wanted ok", err)
+	} else if !reflect.DeepEqual(res, exp) {
+		t.Errorf("got value %#v, wanted value %#v", res, exp)
+	}
+	// not enabled
+	res = &cSources{}
+	if err = ReadFileInto(res, "testdata/include/main.gcfg"); err == nil {
+		t.Errorf("got ok, wanted error when includes are not enabled")
+	}
+}
+
+func TestIncludeErrors(t *testing.T) {
+	for i, tt := range []struct {
+		filename string
+		kind     ErrorKind
+		pos      string
+	}{
+		{"testdata/include/cycle.gcfg", IncludeError, "cycle2.gcfg:2:1"},
+		{"testdata/include/bad.gcfg", ParseError, filepath.Join("sub", "bad.gcfg") + ":3:1"},
+	} {
+		err := ReadFileInto(&cSources{}, tt.filename, AllowIncludes())
+		e, ok := err.(*Error)
+		switch {
+		case !ok:
+			t.Errorf("%d fail: got error %#v, wanted *Error", i, err)
+		case e.Kind != tt.kind:
+			t.Errorf("%d fail: got kind %v, wanted %v: %v", i, e.Kind, tt.kind, e)
+		case !strings.HasSuffix(e.Pos.String(), tt.pos):
+			t.Errorf("%d fail: got position %v, wanted suffix %v", i, e.Pos, tt.pos)
+		}
+	}
+}
+
+func TestIncludeIf(t *testing.T) {
+	os.Setenv("GCFG_TEST_INCLUDE", "prod-1")
+	defer os.Unsetenv("GCFG_TEST_INCLUDE")
+	for i, tt := range []struct {
+		cond string
+		exp  int
+		ok   bool
+	}{
+		{"env:GCFG_TEST_INCLUDE", 2, true},
+		{"env:GCFG_TEST_INCLUDE=prod-*", 2, true},
+		{"env:GCFG_TEST_INCLUDE=dev-*", 1, true},
+		{"env:GCFG_TEST_UNSET", 1, true},
+		{"hostname:*", 2, true},
+		{"gitdir:/", 1, false},
+		{"env:GCFG_TEST_INCLUDE=[", 1, false},
+	} {
+		res := &cSources{}
+		cfg := "[section]\nint=1\n[includeIf \"" + tt.cond + "\"]\npath=testdata/include/sub/b.gcfg"
+		err := ReadStringInto(res, cfg, AllowIncludes())
+		switch {
+		case tt.ok && err != nil:
+			t.Errorf("%d fail: got error %v, wanted ok", i, err)
+		case !tt.ok && err == nil:
+			t.Errorf("%d fail: got ok, wanted error", i)
+		case res.Section.Int != tt.exp:
+			t.Errorf("%d fail: got int %d, wanted %d", i, res.Section.Int, tt.exp)
+		}
+	}
+}
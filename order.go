@@ -0,0 +1,66 @@
+package gcfg
+
+// OrderSubsections makes Write write the subsections of each section with
+// subsections in the order returned by order. order is passed the section
+// name, as derived from the field, and the names of its subsections in sorted
+// order; the empty name, for the section without subsection name, sorts
+// first. Subsections left out by order are written after the others.
+func OrderSubsections(order func(sect string, subs []string) []string) WriteOption {
+	return func(ws *writeState) { ws.order = order }
+}
+
+// An Order records the order in which the subsections of sections with
+// subsections are first set by a read, as enabled with RecordOrder.
+type Order struct {
+	subs map[string][]string
+}
+
+// RecordOrder makes the read record the order of subsections in o, adding to
+// the subsections recorded before.
+//
+// For example, to write a config in the order it was read:
+//
+//	var order gcfg.Order
+//	err := gcfg.ReadFileInto(&cfg, filename, gcfg.RecordOrder(&order))
+//	...
+//	err = gcfg.Write(&cfg, w, gcfg.OrderSubsections(order.Subsections))
+func RecordOrder(o *Order) ReadOption {
+	return func(rs *readState) { rs.order = o }
+}
+
+// add records the subsection sub of section sect, unless already recorded.
+func (o *Order) add(sect, sub string) {
+	if o.subs == nil {
+		o.subs = map[string][]string{}
+	}
+	for _, s := range o.subs[sect] {
+		if s == sub {
+			return
+		}
+	}
+	o.subs[sect] = append(o.subs[sect], sub)
+}
+
+// Subsections returns the subsection names subs of section sect in the order
+// recorded, followed by those not recorded, in their order in subs. It can be
+// passed to OrderSubsections.
+func (o *Order) Subsections(sect string, subs []string) []string {
+	in := map[string]bool{}
+	for _, sub := range subs {
+		in[sub] = true
+	}
+	var res []string
+	seen := map[string]bool{}
+	for _, sub := range o.subs[sect] {
+		if in[sub] {
+			res = append(res, sub)
+			seen[sub] = true
+		}
+	}
+	for _, sub := range subs {
+		if !seen[sub] {
+			res = append(res, sub)
+		}
+	}
+	return res
+}
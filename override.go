@@ -0,0 +1,89 @@
+package gcfg
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Overrides is a list of variable definitions of the form
+// "section.variable=value" or "section.subsection.variable=value", as used
+// with the -c option of git. The subsection name extends from the first to the
+// last dot, and may itself contain dots. A definition without '=' sets a blank
+// value.
+//
+// Overrides is a Source, and used as the last input of ReadSourcesInto, it
+// overrides the values from gcfg files. It also implements flag.Value, with
+// each use of the flag adding a definition:
+//
+//	var overrides gcfg.Overrides
+//	flag.Var(&overrides, "c", "set config variable (section.variable=value)")
+//
+// Error positions refer to a file named "command-line", with one line for
+// each definition.
+type Overrides []string
+
+// String implements flag.Value.
+func (o *Overrides) String() string {
+	if o == nil {
+		return ""
+	}
+	return strings.Join(*o, " ")
+}
+
+// Set implements flag.Value; it adds the definition s to the list.
+func (o *Overrides) Set(s string) error {
+	if _, err := parseOverride(s); err != nil {
+		return err
+	}
+	*o = append(*o, s)
+	return nil
+}
+
+// override is a parsed variable definition.
+type override struct {
+	sect, sub, name string
+	blank           bool
+	value           string
+}
+
+// parseOverride splits the definition s into its parts.
+func parseOverride(s string) (override, error) {
+	o, key := override{blank: true}, s
+	if i := strings.Index(s, "="); i >= 0 {
+		key, o.value, o.blank = s[:i], s[i+1:], false
+	}
+	first, last := strings.Index(key, "."), strings.LastIndex(key, ".")
+	if first <= 0 || last == len(key)-1 {
+		return o, errors.New("expected section.variable or section.subsection.variable")
+	}
+	o.sect, o.name = key[:first], key[last+1:]
+	if first != last {
+		o.sub = key[first+1 : last]
+		if o.sub == "" {
+			return o, errors.New("empty subsection name")
+		}
+	}
+	return o, nil
+}
+
+func (o Overrides) readInto(rs *readState, config interface{}) error {
+	src := strings.Join(o, "\n")
+	file := rs.fset.AddFile("command-line", rs.fset.Base(), len(src))
+	file.SetLinesForContent([]byte(src))
+	off := 0
+	for _, s := range o {
+		pos := rs.fset.Position(file.Pos(off))
+		off += len(s) + 1
+		if d, err := parseOverride(s); err != nil {
+			rs.report(&Error{Pos: pos, Kind: SyntaxError,
+				Err: fmt.Errorf("%v: %q", err, s)})
+		} else {
+			rs.setAt(config, d.sect, d.sub, d.name, d.blank, d.value, pos, pos)
+		}
+		if rs.errs.Len() > 0 && !rs.collect {
+			return rs.err()
+		}
+	}
+	return nil
+}
@@ -0,0 +1,63 @@
+package gcfg
+
+import (
+	"flag"
+	"io/ioutil"
+	"reflect"
+	"testing"
+)
+
+func TestOverrides(t *testing.T) {
+	for i, tt := range []struct {
+		overrides Overrides
+		exp       *cSources
+		ok        bool
+	}{
+		{Overrides{"section.name=value", "section.int=2"},
+			&cSources{Section: cSourcesS1{Name: "value", Int: 2}}, true},
+		{Overrides{"section.name=a=b"},
+			&cSources{Section: cSourcesS1{Name: "a=b"}}, true},
+		{Overrides{"section.multi=a", "section.multi", "section.multi=b"},
+			&cSources{Section: cSourcesS1{Multi: []string{"b"}}}, true},
+		{Overrides{"sub.a.b.name=value"},
+			&cSources{Sub: map[string]*cSourcesS1{"a.b": {Name: "value"}}}, true},
+		{Overrides{"section=value"}, &cSources{}, false},
+		{Overrides{".name=value"}, &cSources{}, false},
+		{Overrides{"section.=value"}, &cSources{}, false},
+		{Overrides{"sub..name=value"}, &cSources{}, false},
+		{Overrides{"section.int=x"}, &cSources{}, false},
+	} {
+		res := &cSources{}
+		err := ReadSourcesInto(res, []Source{tt.overrides})
+		switch {
+		case tt.ok && err != nil:
+			t.Errorf("%d fail: got error %v, wanted ok", i, err)
+		case !tt.ok && err == nil:
+			t.Errorf("%d fail: got value %#v, wanted error", i, res)
+		case tt.ok && !reflect.DeepEqual(res, tt.exp):
+			t.Errorf("%d fail: got value %#v, wanted value %#v", i, res, tt.exp)
+		}
+	}
+}
+
+func TestOverridesFlag(t *testing.T) {
+	var overrides Overrides
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	fs.SetOutput(ioutil.Discard)
+	fs.Var(&overrides, "c", "set config variable")
+	if err := fs.Parse([]string{"-c", "section.name=flag", "-c", "section.int=x"}); err != nil {
+		t.Fatalf("got error %v, wanted ok", err)
+	}
+	if err := fs.Parse([]string{"-c", "section"}); err == nil {
+		t.Errorf("got ok, wanted error for invalid definition")
+	}
+	res := &cSources{}
+	err := ReadSourcesInto(res, []Source{StringSource("file", "[section]\nname=file"), overrides})
+	e, ok := err.(*Error)
+	if !ok || e.Kind != ParseError || e.Pos.String() != "command-line:2:1" {
+		t.Errorf("got error %v, wanted parse error at command-line:2:1", err)
+	}
+	if res.Section.Name != "flag" {
+		t.Errorf("got %q, wanted %q", res.Section.Name, "flag")
+	}
+}
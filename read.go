@@ -1,181 +1,208 @@
 package gcfg
 
 import (
-	"fmt"
+	"errors"
 	"io"
-	"io/ioutil"
-	"os"
-	"strings"
+	"path/filepath"
+	"reflect"
 )
 
 import (
+	"github.com/baobabus/gcfg/ast"
 	"github.com/baobabus/gcfg/scanner"
 	"github.com/baobabus/gcfg/token"
 )
 
-var unescape = map[rune]rune{'\\': '\\', '"': '"', 'n': '\n', 't': '\t'}
+// A ReadOption configures a single read operation.
+type ReadOption func(*readState)
 
-// no error: invalid literals should be caught by scanner
-func unquote(s string) string {
-	u, q, esc := make([]rune, 0, len(s)), false, false
-	for _, c := range s {
-		if esc {
-			uc, ok := unescape[c]
-			switch {
-			case ok:
-				u = append(u, uc)
-				fallthrough
-			case !q && c == '\n':
-				esc = false
-				continue
-			}
-			panic("invalid escape sequence")
-		}
-		switch c {
-		case '"':
-			q = !q
-		case '\\':
-			esc = true
-		default:
-			u = append(u, c)
-		}
+// CollectErrors makes the read continue past invalid lines instead of
+// stopping at the first error. Parsing resumes at the next line or section
+// header, and all errors are returned together as a scanner.ErrorList, sorted
+// by position and with errors repeated on the same line reported once.
+func CollectErrors() ReadOption {
+	return func(rs *readState) { rs.collect = true }
+}
+
+// readState holds the state of a single read operation.
+type readState struct {
+	fset     *token.FileSet
+	collect  bool
+	includes bool
+	errs     scanner.ErrorList
+	// handles unknown sections and variables instead of reporting them
+	unknown func(e *Error, blank bool)
+	reading []string // absolute names of files being read, outermost first
+	// multi-valued variables holding default values
+	defaulted map[fieldKey]bool
+	// sections and variables present in the input
+	sections map[sectKey]bool
+	vars     map[fieldKey]bool
+	// position of the first header of each section
+	sectionPos map[sectKey]token.Position
+	// positions of the values of multi-valued variables read
+	valuePos map[fieldKey][]token.Position
+	// position of the value being set
+	pos token.Position
+	// records the order of subsections; or nil
+	order *Order
+	// setters for the types given parsers as options
+	typeSetters map[reflect.Type]setter
+	// registry of type parsers; nil for DefaultRegistry
+	registry *Registry
+	// setters in order of precedence, computed on first use
+	ss []setter
+	// name of sources without file name; see SourceName
+	name string
+	// size limit of each input; zero if unlimited
+	maxSize int64
+	// prefix of environment variables applied after the sources; see EnvPrefix
+	envPrefix string
+	// checks applied to the config once read; see ValidateConfig
+	validators []func(config interface{}) error
+}
+
+func newReadState(opts []ReadOption) *readState {
+	rs := &readState{
+		fset:       token.NewFileSet(),
+		defaulted:  map[fieldKey]bool{},
+		sections:   map[sectKey]bool{},
+		sectionPos: map[sectKey]token.Position{},
+		vars:       map[fieldKey]bool{},
+		valuePos:   map[fieldKey][]token.Position{},
+	}
+	for _, opt := range opts {
+		opt(rs)
+	}
+	return rs
+}
+
+// report records e.
+func (rs *readState) report(e *Error) {
+	rs.errs.AddError(e.Pos, e.msg(), e)
+}
+
+// err returns the errors encountered so far, or nil if there were none.
+// Unless collecting errors, the first *Error is returned.
+func (rs *readState) err() error {
+	if rs.errs.Len() == 0 {
+		return nil
+	}
+	if !rs.collect {
+		return rs.errs[0].Err
+	}
+	rs.errs.RemoveDuplicates()
+	return rs.errs
+}
+
+// setAt sets the variable name in section sect and subsection sub of config.
+// Errors are recorded at sectpos if the section cannot be resolved, and at
+// pos otherwise; unknown variables passed to the unknown handler are always
+// at pos. setAt reports whether the variable was set.
+func (rs *readState) setAt(config interface{}, sect, sub, name string,
+	blank bool, value string, sectpos, pos token.Position) bool {
+	rs.pos = pos
+	err := rs.set(config, sect, sub, name, blank, value)
+	rs.pos = token.Position{}
+	if err == nil {
+		return true
+	}
+	e := err.(*Error)
+	e.Pos = pos
+	if rs.unknown != nil && (e.Kind == UnknownSection || e.Kind == UnknownVariable) {
+		e.Subsection, e.Variable, e.Value = sub, name, value
+		rs.unknown(e, blank)
+		return false
 	}
-	if q {
-		panic("missing end quote")
+	if e.Kind == UnknownSection {
+		e.Pos = sectpos
+	}
+	rs.report(e)
+	return false
+}
+
+// readSource parses src as a file named filename and reads it into config.
+// Sources without file name are named as given by SourceName.
+func (rs *readState) readSource(config interface{}, filename string, src []byte) error {
+	if filename == "" {
+		filename = rs.name
 	}
-	if esc {
-		panic("invalid escape sequence")
+	f, err := ast.Parse(rs.fset, filename, src)
+	var syntax scanner.ErrorList
+	if err != nil {
+		syntax = err.(scanner.ErrorList)
 	}
-	return string(u)
+	return rs.readTree(config, rs.fset, f, syntax)
 }
 
-func readInto(config interface{}, fset *token.FileSet, file *token.File, src []byte) error {
-	var s scanner.Scanner
-	var errs scanner.ErrorList
-	s.Init(file, src, func(p token.Position, m string) { errs.Add(p, m) }, 0)
-	sect, sectsub := "", ""
-	pos, tok, lit := s.Scan()
-	errfn := func(msg string) error {
-		return fmt.Errorf("%s: %s", fset.Position(pos), msg)
+// readTree sets the variables defined in the document tree f, parsed with
+// fset, into config. The syntax errors found when parsing f are reported in
+// order with the errors setting the variables, so that unless collecting
+// errors, reading stops at the first error in the input. Errors are recorded
+// in rs; unless collecting errors, the first one is also returned.
+func (rs *readState) readTree(config interface{}, fset *token.FileSet, f *ast.File, syntax scanner.ErrorList) error {
+	if f.Name != "" {
+		abs, err := filepath.Abs(f.Name)
+		if err != nil {
+			abs = f.Name
+		}
+		rs.reading = append(rs.reading, abs)
+		defer func() { rs.reading = rs.reading[:len(rs.reading)-1] }()
 	}
-	for {
-		if errs.Len() > 0 {
-			return errs.Err()
+	// reportSyntax reports the syntax errors before offset off, or all of
+	// them if off is negative, and reports whether reading is to continue
+	reportSyntax := func(off int) bool {
+		for len(syntax) > 0 && (off < 0 || syntax[0].Pos.Offset < off) {
+			e := syntax[0]
+			rs.report(&Error{Pos: e.Pos, Kind: SyntaxError, Err: errors.New(e.Msg)})
+			syntax = syntax[1:]
 		}
-		switch tok {
-		case token.EOF:
-			return nil
-		case token.EOL, token.COMMENT:
-			pos, tok, lit = s.Scan()
-		case token.LBRACK:
-			pos, tok, lit = s.Scan()
-			if errs.Len() > 0 {
-				return errs.Err()
-			}
-			if tok != token.IDENT {
-				return errfn("expected section name")
-			}
-			sect, sectsub = lit, ""
-			pos, tok, lit = s.Scan()
-			if errs.Len() > 0 {
-				return errs.Err()
-			}
-			if tok == token.STRING {
-				sectsub = unquote(lit)
-				if sectsub == "" {
-					return errfn("empty subsection name")
-				}
-				pos, tok, lit = s.Scan()
-				if errs.Len() > 0 {
-					return errs.Err()
-				}
-			}
-			if tok != token.RBRACK {
-				if sectsub == "" {
-					return errfn("expected subsection name or right bracket")
-				}
-				return errfn("expected right bracket")
-			}
-			pos, tok, lit = s.Scan()
-			if tok != token.EOL && tok != token.EOF && tok != token.COMMENT {
-				return errfn("expected EOL, EOF, or comment")
-			}
-		case token.IDENT:
-			if sect == "" {
-				return errfn("expected section header")
-			}
-			n := lit
-			pos, tok, lit = s.Scan()
-			if errs.Len() > 0 {
-				return errs.Err()
-			}
-			blank, v := tok == token.EOF || tok == token.EOL || tok == token.COMMENT, ""
-			if !blank {
-				if tok != token.ASSIGN {
-					return errfn("expected '='")
-				}
-				pos, tok, lit = s.Scan()
-				if errs.Len() > 0 {
-					return errs.Err()
-				}
-				if tok != token.STRING {
-					return errfn("expected value")
-				}
-				v = unquote(lit)
-				pos, tok, lit = s.Scan()
-				if errs.Len() > 0 {
-					return errs.Err()
-				}
-				if tok != token.EOL && tok != token.EOF && tok != token.COMMENT {
-					return errfn("expected EOL, EOF, or comment")
-				}
+		return rs.errs.Len() == 0 || rs.collect
+	}
+	for _, sect := range f.Sections {
+		sectpos := fset.Position(sect.Pos)
+		if !reportSyntax(sectpos.Offset) {
+			return rs.err()
+		}
+		rs.markSection(config, sect.Name, sect.Subsection, sectpos)
+		for _, v := range sect.Vars {
+			pos := fset.Position(v.Pos)
+			if !reportSyntax(pos.Offset) {
+				return rs.err()
 			}
-			err := set(config, sect, sectsub, n, blank, v)
-			if err != nil {
-				return errfn(err.Error())
+			if rs.includes && isIncludeSection(sect.Name) {
+				// relative paths are resolved against the directory of f
+				ipos := pos
+				ipos.Filename = f.Name
+				rs.include(config, sect.Name, sect.Subsection, v.Name, ipos, v.Blank, v.Value)
+			} else {
+				rs.setAt(config, sect.Name, sect.Subsection, v.Name, v.Blank, v.Value, sectpos, pos)
 			}
-		default:
-			if sect == "" {
-				return errfn("expected section header")
+			if rs.errs.Len() > 0 && !rs.collect {
+				return rs.err()
 			}
-			return errfn("expected section header or variable declaration")
 		}
 	}
-	panic("never reached")
+	if !reportSyntax(-1) {
+		return rs.err()
+	}
+	return nil
 }
 
 // ReadInto reads gcfg formatted data from reader and sets the values into the
 // corresponding fields in config.
-func ReadInto(config interface{}, reader io.Reader) error {
-	src, err := ioutil.ReadAll(reader)
-	if err != nil {
-		return err
-	}
-	fset := token.NewFileSet()
-	file := fset.AddFile("", fset.Base(), len(src))
-	return readInto(config, fset, file, src)
+func ReadInto(config interface{}, reader io.Reader, opts ...ReadOption) error {
+	return ReadSourcesInto(config, []Source{ReaderSource("", reader)}, opts...)
 }
 
 // ReadStringInto reads gcfg formatted data from str and sets the values into
 // the corresponding fields in config.
-func ReadStringInto(config interface{}, str string) error {
-	r := strings.NewReader(str)
-	return ReadInto(config, r)
+func ReadStringInto(config interface{}, str string, opts ...ReadOption) error {
+	return ReadSourcesInto(config, []Source{StringSource("", str)}, opts...)
 }
 
 // ReadFileInto reads gcfg formatted data from the file filename and sets the
 // values into the corresponding fields in config.
-func ReadFileInto(config interface{}, filename string) error {
-	f, err := os.Open(filename)
-	if err != nil {
-		return err
-	}
-	defer f.Close()
-	src, err := ioutil.ReadAll(f)
-	if err != nil {
-		return err
-	}
-	fset := token.NewFileSet()
-	file := fset.AddFile(filename, fset.Base(), len(src))
-	return readInto(config, fset, file, src)
+func ReadFileInto(config interface{}, filename string, opts ...ReadOption) error {
+	return ReadSourcesInto(config, []Source{FileSource(filename)}, opts...)
 }
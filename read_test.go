@@ -8,6 +8,10 @@ import (
 	"testing"
 )
 
+import (
+	"github.com/baobabus/gcfg/scanner"
+)
+
 const (
 	// 64 spaces
 	sp64 = "                                                                "
@@ -353,3 +357,77 @@ func TestReadFileIntoUnicode(t *testing.T) {
 		t.Errorf("got %q, wanted %q", res.X甲.X乙, "丙")
 	}
 }
+
+func TestReadStringIntoCollectErrors(t *testing.T) {
+	for i, tt := range []struct {
+		gcfg  string
+		exp   interface{}
+		lines []int
+	}{
+		{"[section]\nname=value", &cBasic{Section: cBasicS1{Name: "value"}}, nil},
+		// resume at next line after syntax, unknown variable and type errors
+		{"[section]\nname=\"value\nint=x\nnonexistent=1\npname=value",
+			&cBasic{Section: cBasicS1{PName: newString("value")}}, []int{2, 3, 4}},
+		// variables of an invalid section are skipped silently
+		{"[nonexistent]\nname=value\n[sub \"\"]\nname=value\n[section]\nint=1",
+			&cBasic{Section: cBasicS1{Int: 1}}, []int{1, 3}},
+		{"name=value\n[section\nname=value\n[section]\nname=value",
+			&cBasic{Section: cBasicS1{Name: "value"}}, []int{1, 2}},
+		// one error per line
+		{"[section]\nname=\\a \\b\n", &cBasic{}, []int{2}},
+		// constraint errors
+		{"[bounds-types-1]\nintR1=9\nintR1=15\nstringL1=a", &cRegTypes{Bounds_Types_1: cBoundsTypes1{IntR1: 15, StringL1: "a"}}, []int{2, 4}},
+	} {
+		res := reflect.New(reflect.TypeOf(tt.exp).Elem()).Interface()
+		err := ReadStringInto(res, tt.gcfg, CollectErrors())
+		if !reflect.DeepEqual(res, tt.exp) {
+			t.Errorf("%d fail: got value %#v, wanted value %#v", i, res, tt.exp)
+		}
+		if tt.lines == nil {
+			if err != nil {
+				t.Errorf("%d fail: got error %v, wanted ok", i, err)
+			}
+			continue
+		}
+		errs, ok := err.(scanner.ErrorList)
+		if !ok {
+			t.Errorf("%d fail: got error %#v, wanted scanner.ErrorList", i, err)
+			continue
+		}
+		var lines []int
+		for _, e := range errs {
+			lines = append(lines, e.Pos.Line)
+		}
+		if !reflect.DeepEqual(lines, tt.lines) {
+			t.Errorf("%d fail: got errors on lines %v, wanted %v: %v", i, lines, tt.lines, errs)
+		}
+	}
+}
+
+func TestReadFirstError(t *testing.T) {
+	for i, tt := range []struct {
+		gcfg string
+		exp  *cBasic
+		line int
+		kind ErrorKind
+	}{
+		// values after the first error are not set
+		{"[section]\nname=a\nint=x\n[section\nname=b", &cBasic{Section: cBasicS1{Name: "a"}}, 3, ParseError},
+		{"[section]\nname=a\n=\nint=x\nname=b", &cBasic{Section: cBasicS1{Name: "a"}}, 3, SyntaxError},
+		{"[section]\nname=a\n[section]\n=", &cBasic{Section: cBasicS1{Name: "a"}}, 4, SyntaxError},
+	} {
+		res := &cBasic{}
+		err := ReadStringInto(res, tt.gcfg)
+		if e, ok := err.(*Error); !ok || e.Pos.Line != tt.line || e.Kind != tt.kind {
+			t.Errorf("%d fail: got error %v, wanted %v on line %d", i, err, tt.kind, tt.line)
+		}
+		if !reflect.DeepEqual(res, tt.exp) {
+			t.Errorf("%d fail: got value %#v, wanted value %#v", i, res, tt.exp)
+		}
+	}
+	// section headers after the first error are not resolved
+	res := &cDefaultEmbedded{}
+	if err := ReadStringInto(res, "[s]\n=\n[extra]"); err == nil || res.CDefaultSections != nil {
+		t.Errorf("got %#v, %v, wanted error and no embedded sections", res.CDefaultSections, err)
+	}
+}
@@ -0,0 +1,142 @@
+package gcfg
+
+import (
+	"fmt"
+	"reflect"
+	"sync"
+)
+
+// A ValueParser parses val into the variable that ptr points to; blank is set
+// for a blank value. It is used for the types implementing an interface, see
+// Registry.RegisterInterface.
+type ValueParser func(ptr interface{}, blank bool, val string) error
+
+// A Registry holds type parsers, used for the types they are registered for
+// in preference to the predefined parsing rules. It is safe for concurrent
+// use, including registering parsers while reads use the registry.
+//
+// Reads use DefaultRegistry, or the registry given with UseRegistry.
+type Registry struct {
+	mu     sync.RWMutex
+	types  map[reflect.Type]setter
+	ifaces []ifaceSetter // in order of registration
+	orders map[reflect.Type]comparer
+}
+
+// ifaceSetter is the setter for the types implementing an interface.
+type ifaceSetter struct {
+	typ reflect.Type
+	set setter
+}
+
+// NewRegistry returns an empty Registry.
+func NewRegistry() *Registry {
+	return &Registry{types: map[reflect.Type]setter{}, orders: map[reflect.Type]comparer{}}
+}
+
+// DefaultRegistry is the registry used by reads not given another one with
+// UseRegistry, and the one RegisterTypeParser registers parsers in.
+var DefaultRegistry = NewRegistry()
+
+// UseRegistry makes the read use the parsers in r instead of those in
+// DefaultRegistry.
+func UseRegistry(r *Registry) ReadOption {
+	return func(rs *readState) { rs.registry = r }
+}
+
+// Register registers typeParser for values of type tgtType, replacing any
+// parser registered for tgtType before.
+func (r *Registry) Register(tgtType reflect.Type, typeParser TypeParser) {
+	r.register(tgtType, typeParserSetter(typeParser))
+}
+
+func (r *Registry) register(tgtType reflect.Type, s setter) {
+	r.mu.Lock()
+	r.types[tgtType] = s
+	r.mu.Unlock()
+}
+
+// registerOrder registers cmp for ordering values of type tgtType parsed with
+// the parsers in r, for checking min and max bounds.
+func (r *Registry) registerOrder(tgtType reflect.Type, cmp comparer) {
+	r.mu.Lock()
+	r.orders[tgtType] = cmp
+	r.mu.Unlock()
+}
+
+// RegisterInterface registers valueParser for values of the types that
+// implement the interface type ifaceType, directly or through a pointer,
+// and have no parser registered for the type itself. If several interfaces
+// apply, the first one registered is used. It replaces any parser registered
+// for ifaceType before.
+func (r *Registry) RegisterInterface(ifaceType reflect.Type, valueParser ValueParser) error {
+	if ifaceType.Kind() != reflect.Interface {
+		return fmt.Errorf("%v is not an interface type", ifaceType)
+	}
+	s := func(d interface{}, blank bool, val string, t metadata) error {
+		return valueParser(d, blank, val)
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for i := range r.ifaces {
+		if r.ifaces[i].typ == ifaceType {
+			r.ifaces[i].set = s
+			return nil
+		}
+	}
+	r.ifaces = append(r.ifaces, ifaceSetter{ifaceType, s})
+	return nil
+}
+
+// Unregister removes the parser registered for the type, or for the
+// interface, tgtType, and the ordering registered for it.
+func (r *Registry) Unregister(tgtType reflect.Type) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.types, tgtType)
+	delete(r.orders, tgtType)
+	for i := range r.ifaces {
+		if r.ifaces[i].typ == tgtType {
+			r.ifaces = append(r.ifaces[:i:i], r.ifaces[i+1:]...)
+			break
+		}
+	}
+}
+
+// Lookup returns the parser used for values of type t, or nil if there is
+// none. The parser is called with a pointer to the variable to set.
+func (r *Registry) Lookup(t reflect.Type) ValueParser {
+	s, _ := r.lookup(t)
+	if s == nil {
+		return nil
+	}
+	return func(ptr interface{}, blank bool, val string) error {
+		return s(ptr, blank, val, metadata{})
+	}
+}
+
+// lookup returns the setter for type t, or nil if there is none, and the
+// ordering registered for t, if any.
+func (r *Registry) lookup(t reflect.Type) (setter, comparer) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	cmp := r.orders[t]
+	if s, ok := r.types[t]; ok {
+		return s, cmp
+	}
+	for _, is := range r.ifaces {
+		if t.Implements(is.typ) || reflect.PtrTo(t).Implements(is.typ) {
+			return is.set, cmp
+		}
+	}
+	return nil, nil
+}
+
+// set is the setter using the parsers in r.
+func (r *Registry) set(d interface{}, blank bool, val string, tt metadata) error {
+	s, cmp := r.lookup(reflect.TypeOf(d).Elem())
+	if s == nil {
+		return errUnsupportedType
+	}
+	return checkedSet(s, cmp, d, blank, val, tt)
+}
@@ -0,0 +1,148 @@
+package gcfg
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// cRegistryLevel implements cRegistrySetter through a pointer.
+type cRegistryLevel int
+
+func (l *cRegistryLevel) setFrom(s string) error {
+	switch s {
+	case "low":
+		*l = 1
+	case "high":
+		*l = 2
+	default:
+		return fmt.Errorf("invalid level %q", s)
+	}
+	return nil
+}
+
+type cRegistrySetter interface {
+	setFrom(s string) error
+}
+
+type cRegistry struct {
+	Section struct {
+		Level cRegistryLevel
+		Pair  cCodecPair
+	}
+}
+
+func setterParser(ptr interface{}, blank bool, val string) error {
+	return ptr.(cRegistrySetter).setFrom(val)
+}
+
+var typeRegistrySetter = reflect.TypeOf((*cRegistrySetter)(nil)).Elem()
+
+func TestRegistry(t *testing.T) {
+	r := NewRegistry()
+	typeLevel := reflect.TypeOf(cRegistryLevel(0))
+	if r.Lookup(typeLevel) != nil || r.Lookup(typeCodecPair) != nil {
+		t.Fatalf("empty registry has parsers")
+	}
+	if err := r.RegisterInterface(typeLevel, setterParser); err == nil {
+		t.Errorf("registered non-interface type as interface")
+	}
+	if err := r.RegisterInterface(typeRegistrySetter, setterParser); err != nil {
+		t.Fatal(err)
+	}
+	r.Register(typeCodecPair, pairParser(":"))
+	in := "[section]\nlevel=high\npair=a:b"
+	cfg := &cRegistry{}
+	if err := NewDecoder(strings.NewReader(in), UseRegistry(r)).Decode(cfg); err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Section.Level != 2 || cfg.Section.Pair != (cCodecPair{"a", "b"}) {
+		t.Errorf("got %+v", cfg.Section)
+	}
+	var l cRegistryLevel
+	if p := r.Lookup(typeLevel); p == nil || p(&l, false, "low") != nil || l != 1 {
+		t.Errorf("lookup by interface: got %v, level %d", p, l)
+	}
+	// the default registry is not used
+	if err := ReadStringInto(&cRegistry{}, in); err == nil {
+		t.Errorf("parsers used without registry")
+	}
+	// exact types take precedence over interfaces
+	r.Register(typeLevel, func(blank bool, val string) (interface{}, error) {
+		return cRegistryLevel(len(val)), nil
+	})
+	cfg = &cRegistry{}
+	if err := ReadStringInto(cfg, in, UseRegistry(r)); err != nil || cfg.Section.Level != 4 {
+		t.Errorf("exact type: got %d, %v", cfg.Section.Level, err)
+	}
+	r.Unregister(typeLevel)
+	r.Unregister(typeRegistrySetter)
+	if r.Lookup(typeLevel) != nil {
+		t.Errorf("parser found after unregistering")
+	}
+	if err := ReadStringInto(&cRegistry{}, "[section]\nlevel=high", UseRegistry(r)); err == nil {
+		t.Errorf("unregistered parser used")
+	}
+}
+
+func TestRegistryConcurrent(t *testing.T) {
+	r := NewRegistry()
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 100; i++ {
+			r.Register(typeCodecPair, pairParser(":"))
+			r.Unregister(typeCodecPair)
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 100; i++ {
+			// fails whenever the parser is not registered
+			ReadStringInto(&cCodec{}, "[section]\npair=a:b", UseRegistry(r))
+		}
+	}()
+	wg.Wait()
+}
+
+type cRegistryAssign struct {
+	Section struct {
+		Map   map[string]int
+		Slice cRegistrySlice
+		Ptr   *cCodecPair
+	}
+}
+
+type cRegistrySlice []string
+
+func TestTypeParserAssign(t *testing.T) {
+	r := NewRegistry()
+	r.Register(reflect.TypeOf(map[string]int{}), func(blank bool, val string) (interface{}, error) {
+		return map[string]int{val: 1}, nil
+	})
+	r.Register(reflect.TypeOf(cRegistrySlice{}), func(blank bool, val string) (interface{}, error) {
+		s := cRegistrySlice(strings.Split(val, ","))
+		return &s, nil
+	})
+	r.Register(typeCodecPair, pairParser(":"))
+	cfg := &cRegistryAssign{}
+	in := "[section]\nmap=a\nslice=a,b\nptr=a:b"
+	if err := ReadStringInto(cfg, in, UseRegistry(r)); err != nil {
+		t.Fatal(err)
+	}
+	s := cfg.Section
+	if !reflect.DeepEqual(s.Map, map[string]int{"a": 1}) ||
+		!reflect.DeepEqual(s.Slice, cRegistrySlice{"a", "b"}) ||
+		s.Ptr == nil || *s.Ptr != (cCodecPair{"a", "b"}) {
+		t.Errorf("got %+v", s)
+	}
+	r.Register(typeCodecPair, func(blank bool, val string) (interface{}, error) {
+		return val, nil
+	})
+	if err := ReadStringInto(cfg, in, UseRegistry(r)); err == nil {
+		t.Errorf("no error for value of wrong type")
+	}
+}
@@ -0,0 +1,158 @@
+package gcfg
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+)
+
+import (
+	"github.com/baobabus/gcfg/token"
+)
+
+// sectKey identifies a section or subsection of a particular config value.
+type sectKey struct {
+	field fieldKey
+	sub   string
+}
+
+var errMissingSection = fmt.Errorf("missing section")
+var errMissingVariable = fmt.Errorf("missing variable")
+
+// markSection records the presence of the section sect and subsection sub in
+// the input with a header at pos, if config has a field for it.
+func (rs *readState) markSection(config interface{}, sect, sub string, pos token.Position) {
+	vc := reflect.ValueOf(config).Elem()
+	vSect, _ := fieldFold(vc, sect, rs.sectionAlloc(vc))
+	if vSect.IsValid() {
+		k := sectKey{fieldKeyOf(vSect), sub}
+		rs.sections[k] = true
+		if _, ok := rs.sectionPos[k]; !ok {
+			rs.sectionPos[k] = pos
+		}
+	}
+}
+
+// checkRequired reports the required sections and variables of config that
+// are missing from the input, and the violations of count constraints.
+//
+// Section fields with the "required" option must be present in the input; for
+// sections with subsections, at least one subsection, or as many as given by
+// the "mincount" tag, and at most as many as given by the "maxcount" tag.
+// Variable fields with the "required" option must be set in each section or
+// subsection present in the input, and multi-valued variables there must have
+// as many values as allowed by their "mincount" and "maxcount" tags.
+func (rs *readState) checkRequired(config interface{}) {
+	vc := reflect.ValueOf(config).Elem()
+	for _, f := range structInfoOf(vc.Type()).fields {
+		vSect := fieldByIndex(vc, f.index, nil)
+		sect, t := f.name, f.meta
+		switch {
+		case !vSect.IsValid():
+			continue
+		case vSect.Kind() == reflect.Map:
+			if vSect.Type().Elem().Kind() != reflect.Ptr ||
+				vSect.Type().Elem().Elem().Kind() != reflect.Struct {
+				continue
+			}
+			// subsections present in the input, or set before reading
+			fk, seen := fieldKeyOf(vSect), map[string]bool{}
+			for _, k := range vSect.MapKeys() {
+				seen[k.String()] = false
+			}
+			for k := range rs.sections {
+				if k.field == fk {
+					seen[k.sub] = true
+				}
+			}
+			min := t.constraints.mincount
+			if t.required && min < 1 {
+				min = 1
+			}
+			if n := len(seen); n < min {
+				rs.report(newError(MissingError, sect, "", "",
+					fmt.Errorf("%d subsections required, found %d", min, n)))
+			} else if max := t.constraints.maxcount; max >= 0 && n > max {
+				rs.report(newError(CountError, sect, "", "",
+					fmt.Errorf("at most %d subsections allowed, found %d", max, n)))
+			}
+			var subs []string
+			for sub, present := range seen {
+				if present {
+					subs = append(subs, sub)
+				}
+			}
+			sort.Strings(subs)
+			for _, sub := range subs {
+				pv := vSect.MapIndex(reflect.ValueOf(sub))
+				if !pv.IsValid() {
+					// subsection header without variables
+					pv = reflect.New(vSect.Type().Elem().Elem())
+				}
+				rs.checkRequiredVars(pv.Elem(), sect, sub, rs.sectionPos[sectKey{fk, sub}])
+			}
+		case vSect.Kind() == reflect.Struct ||
+			vSect.Kind() == reflect.Ptr && vSect.Type().Elem().Kind() == reflect.Struct:
+			k := sectKey{fieldKeyOf(vSect), ""}
+			if !rs.sections[k] {
+				if t.required {
+					rs.report(newError(MissingError, sect, "", "", errMissingSection))
+				}
+				continue
+			}
+			if vSect.Kind() == reflect.Ptr {
+				if vSect.IsNil() {
+					// section header without variables
+					vSect = reflect.New(vSect.Type().Elem())
+				}
+				vSect = vSect.Elem()
+			}
+			rs.checkRequiredVars(vSect, sect, "", rs.sectionPos[k])
+		}
+	}
+}
+
+// checkRequiredVars reports the required variables of section struct vSect
+// that are missing from the input, at the position pos of its section header,
+// and the multi-valued variables with too few or too many values.
+func (rs *readState) checkRequiredVars(vSect reflect.Value, sect, sub string, pos token.Position) {
+	for _, f := range structInfoOf(vSect.Type()).fields {
+		vVar := fieldByIndex(vSect, f.index, nil)
+		if f.meta.required && (!vVar.IsValid() || !rs.vars[fieldKeyOf(vVar)]) {
+			e := newError(MissingError, sect, sub, f.name, errMissingVariable)
+			e.Pos = pos
+			rs.report(e)
+		}
+		if vVar.IsValid() && isMulti(vVar) {
+			rs.checkCount(vVar, f.meta, sect, sub, f.name)
+		}
+	}
+}
+
+// checkCount reports whether the number of values of the multi-valued variable
+// vVar violates the mincount or maxcount constraint in t. Too few values are
+// reported at the position of the last value read, too many at the first
+// value in excess.
+func (rs *readState) checkCount(vVar reflect.Value, t metadata, sect, sub, name string) {
+	min, max := t.constraints.mincount, t.constraints.maxcount
+	n, pos := vVar.Len(), rs.valuePos[fieldKeyOf(vVar)]
+	var e *Error
+	switch {
+	case min >= 0 && n < min:
+		e = newError(CountError, sect, sub, name,
+			fmt.Errorf("at least %d values required, found %d", min, n))
+		if len(pos) > 0 {
+			e.Pos = pos[len(pos)-1]
+		}
+	case max >= 0 && n > max:
+		e = newError(CountError, sect, sub, name,
+			fmt.Errorf("at most %d values allowed, found %d", max, n))
+		// values set before reading come first
+		if i := max - (n - len(pos)); i >= 0 && i < len(pos) {
+			e.Pos = pos[i]
+		}
+	default:
+		return
+	}
+	rs.report(e)
+}
@@ -0,0 +1,127 @@
+package gcfg
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+import (
+	"github.com/baobabus/gcfg/scanner"
+)
+
+type cRequired struct {
+	Section cRequiredS1  `gcfg:",required"`
+	Ptr     *cRequiredS1 `gcfg:",required"`
+	Opt     cRequiredS1
+	Server  map[string]*cRequiredS1 `gcfg:",required"`
+	Pool    map[string]*cRequiredS1 `mincount:"2"`
+}
+type cRequiredS1 struct {
+	Host string `gcfg:",required"`
+	Port int
+}
+
+func TestRequired(t *testing.T) {
+	const ok = "[section]\nhost=a\n[ptr]\nhost=b\n[server \"a\"]\nhost=c\n"
+	const pools = "[pool \"a\"]\nhost=d\n[pool \"b\"]\nhost=e\n"
+	for i, tt := range []struct {
+		gcfg    string
+		missing [][3]string
+	}{
+		{ok + pools, nil},
+		{ok, [][3]string{{"pool", "", ""}}},
+		{pools, [][3]string{{"section", "", ""}, {"ptr", "", ""}, {"server", "", ""}}},
+		// section headers without variables
+		{ok + "[opt]\n[pool \"a\"]\n[pool \"b\"]\nport=1\n",
+			[][3]string{{"opt", "", "host"}, {"pool", "a", "host"}, {"pool", "b", "host"}}},
+		// each subsection checked individually
+		{"[section]\nport=1\n[ptr]\nhost=b\n[server \"a\"]\nport=1\n[server \"b\"]\nhost=c\n[pool \"a\"]\nhost=d\n",
+			[][3]string{{"section", "", "host"}, {"pool", "", ""}, {"server", "a", "host"}}},
+	} {
+		err := ReadStringInto(&cRequired{}, tt.gcfg, CollectErrors())
+		var missing [][3]string
+		if errs, ok := err.(scanner.ErrorList); ok {
+			for _, e := range errs.Unwrap() {
+				if e, ok := e.(*Error); ok && e.Kind == MissingError {
+					missing = append(missing, [3]string{e.Section, e.Subsection, e.Variable})
+				} else {
+					t.Errorf("%d fail: got unexpected error %v", i, e)
+				}
+			}
+		} else if err != nil {
+			t.Errorf("%d fail: got error %v, wanted scanner.ErrorList", i, err)
+		}
+		if !reflect.DeepEqual(missing, tt.missing) {
+			t.Errorf("%d fail: got missing %v, wanted %v", i, missing, tt.missing)
+		}
+	}
+	err := ReadStringInto(&cRequired{}, "[section]\nhost=a\n"+pools)
+	if e, ok := err.(*Error); !ok || e.Kind != MissingError || e.Section != "ptr" {
+		t.Errorf("got error %v, wanted missing section ptr", err)
+	}
+	// missing variables are reported at the header of their section
+	err = ReadStringInto(&cRequired{}, ok+pools+"[server \"b\"]\nport=1\n")
+	if e, ok := err.(*Error); !ok || e.Kind != MissingError || e.Subsection != "b" || e.Pos.Line != 11 {
+		t.Errorf("got error %v, wanted missing variable at line 11", err)
+	}
+	// all missing variables of a section are collected
+	res := &struct {
+		Section struct {
+			A, B string `gcfg:",required"`
+		}
+	}{}
+	err = ReadStringInto(res, "[section]", CollectErrors())
+	if errs, ok := err.(scanner.ErrorList); !ok || len(errs) != 2 || errs[1].Pos.Line != 1 {
+		t.Errorf("got error %v, wanted 2 missing variables at line 1", err)
+	}
+}
+
+type cCount struct {
+	Section struct {
+		Multi  []string `mincount:"2" maxcount:"3" maxlen:"3"`
+		Ints   []int    `max:"9" default:"1,2"`
+		Counts []int    `maxcount:"2" default:"1,2"`
+	}
+	Sub map[string]*struct{ Name string } `maxcount:"1"`
+}
+
+func TestCount(t *testing.T) {
+	for i, tt := range []struct {
+		gcfg string
+		kind ErrorKind
+		line int
+		msg  string
+	}{
+		{"[section]\nmulti=a\nmulti=b", 0, 0, ""},
+		{"[section]\nmulti=a", CountError, 2, "at least 2 values required, found 1"},
+		{"[section]\nints=1", CountError, 0, "at least 2"},
+		{"[section]\nmulti=a\nmulti=b\nmulti=c\nmulti=d\nmulti=e", CountError, 5,
+			"at most 3 values allowed, found 5"},
+		// counted once the whole input is read
+		{"[section]\nmulti=a\nmulti=b\nmulti=c\nmulti=d\nmulti\nmulti=e\nmulti=f", 0, 0, ""},
+		{"[section]\nmulti=a\nmulti=b\nmulti\nmulti=c", CountError, 5, "found 1"},
+		// defaults count, unless replaced
+		{"[section]\nmulti=a\nmulti=b\ncounts=3", 0, 0, ""},
+		{"[section]\nmulti=a\nmulti=b\n[section]\ncounts=3\ncounts=4\ncounts=5", CountError, 7,
+			"at most 2 values allowed, found 3"},
+		// constraints of each value
+		{"[section]\nmulti=a\nmulti=abcd\nmulti=b", LengthError, 3, "value 2: "},
+		{"[section]\nmulti=a\nmulti=b\nints=10", BoundsError, 4, "value 1: "},
+		{"[sub \"a\"]\nname=x\n[sub \"b\"]\nname=y", CountError, 0, "at most 1 subsections allowed"},
+	} {
+		err := ReadStringInto(&cCount{}, tt.gcfg)
+		if tt.kind == 0 {
+			if err != nil {
+				t.Errorf("%d: unexpected error %v", i, err)
+			}
+			continue
+		}
+		e, ok := err.(*Error)
+		if !ok || e.Kind != tt.kind || e.Pos.Line != tt.line ||
+			!strings.Contains(e.Error(), tt.msg) {
+			t.Errorf("%d: got error %v, want %v at line %d containing %q",
+				i, err, tt.kind, tt.line, tt.msg)
+		}
+	}
+}
@@ -17,11 +17,12 @@ import (
 // In an ErrorList, an error is represented by an *Error.
 // The position Pos, if valid, points to the beginning of
 // the offending token, and the error condition is described
-// by Msg.
+// by Msg. Err, if not nil, holds the underlying error.
 //
 type Error struct {
 	Pos token.Position
 	Msg string
+	Err error
 }
 
 // Error implements the error interface.
@@ -34,6 +35,9 @@ func (e Error) Error() string {
 	return e.Msg
 }
 
+// Unwrap returns the underlying error, if any.
+func (e Error) Unwrap() error { return e.Err }
+
 // ErrorList is a list of *Errors.
 // The zero value for an ErrorList is an empty ErrorList ready to use.
 //
@@ -41,7 +45,13 @@ type ErrorList []*Error
 
 // Add adds an Error with given position and error message to an ErrorList.
 func (p *ErrorList) Add(pos token.Position, msg string) {
-	*p = append(*p, &Error{pos, msg})
+	*p = append(*p, &Error{pos, msg, nil})
+}
+
+// AddError adds an Error with given position, error message and underlying
+// error to an ErrorList.
+func (p *ErrorList) AddError(pos token.Position, msg string, err error) {
+	*p = append(*p, &Error{pos, msg, err})
 }
 
 // Reset resets an ErrorList to no errors.
@@ -72,12 +82,13 @@ func (p ErrorList) Sort() {
 }
 
 // RemoveMultiples sorts an ErrorList and removes all but the first error per line.
+// Errors without line information are all kept, in their original order.
 func (p *ErrorList) RemoveMultiples() {
-	sort.Sort(p)
+	sort.Stable(p)
 	var last token.Position // initial last.Line is != any legal error line
 	i := 0
 	for _, e := range *p {
-		if e.Pos.Filename != last.Filename || e.Pos.Line != last.Line {
+		if !e.Pos.IsValid() || e.Pos.Filename != last.Filename || e.Pos.Line != last.Line {
 			last = e.Pos
 			(*p)[i] = e
 			i++
@@ -86,6 +97,29 @@ func (p *ErrorList) RemoveMultiples() {
 	(*p) = (*p)[0:i]
 }
 
+// RemoveDuplicates sorts an ErrorList and removes all but the first error
+// with the same message per line. Errors without line information are all
+// kept, in their original order.
+func (p *ErrorList) RemoveDuplicates() {
+	sort.Stable(p)
+	i := 0
+	for _, e := range *p {
+		dup := false
+		for j := i - 1; j >= 0 && e.Pos.IsValid(); j-- {
+			q := (*p)[j]
+			if q.Pos.Filename != e.Pos.Filename || q.Pos.Line != e.Pos.Line {
+				break
+			}
+			dup = dup || q.Msg == e.Msg
+		}
+		if !dup {
+			(*p)[i] = e
+			i++
+		}
+	}
+	(*p) = (*p)[0:i]
+}
+
 // An ErrorList implements the error interface.
 func (p ErrorList) Error() string {
 	switch len(p) {
@@ -97,6 +131,20 @@ func (p ErrorList) Error() string {
 	return fmt.Sprintf("%s (and %d more errors)", p[0], len(p)-1)
 }
 
+// Unwrap returns the errors in the list, substituting the underlying error
+// for each entry that has one.
+func (p ErrorList) Unwrap() []error {
+	errs := make([]error, len(p))
+	for i, e := range p {
+		if e.Err != nil {
+			errs[i] = e.Err
+		} else {
+			errs[i] = e
+		}
+	}
+	return errs
+}
+
 // Err returns an error equivalent to this error list.
 // If the list is empty, Err returns nil.
 func (p ErrorList) Err() error {
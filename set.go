@@ -6,8 +6,6 @@ import (
 	"reflect"
 	"strconv"
 	"strings"
-	"unicode"
-	"unicode/utf8"
 
 	"github.com/baobabus/gcfg/types"
 )
@@ -16,6 +14,10 @@ type metadata struct {
 	ident       string
 	intMode     string
 	callback    string
+	required    bool
+	rest        bool
+	omitempty   bool
+	percent     bool
 	constraints constraints
 	err         error
 }
@@ -43,6 +45,18 @@ func newMetadata(ts string, tag reflect.StructTag) metadata {
 		if strings.HasPrefix(tse, "cb=") {
 			t.callback = tse[len("cb="):]
 		}
+		if tse == "required" {
+			t.required = true
+		}
+		if tse == "rest" {
+			t.rest = true
+		}
+		if tse == "omitempty" {
+			t.omitempty = true
+		}
+		if tse == "percent" {
+			t.percent = true
+		}
 	}
 	t.constraints.min = tag.Get("min")
 	t.constraints.max = tag.Get("max")
@@ -50,68 +64,25 @@ func newMetadata(ts string, tag reflect.StructTag) metadata {
 	if t.err == nil {
 		t.constraints.maxlen, t.err = getIntTag(tag, "maxlen", -1)
 	}
-	return t
-}
-
-func fieldFold(v reflect.Value, name string) (reflect.Value, []int, metadata) {
-	var n string
-	ixs := []int{}
-	r0, _ := utf8.DecodeRuneInString(name)
-	if unicode.IsLetter(r0) && !unicode.IsLower(r0) && !unicode.IsUpper(r0) {
-		n = "X"
-	}
-	n += strings.Replace(name, "-", "_", -1)
-	// Find by tag first
-	f, ok := v.Type().FieldByNameFunc(func(fieldName string) bool {
-		// TODO Implement proper recursive traversal
-		// to deal with potential field name collisions
-		defer func() {
-			recover()
-		}()
-		if !v.FieldByName(fieldName).CanSet() {
-			return false
-		}
-		f, _ := v.Type().FieldByName(fieldName)
-		t := newMetadata(f.Tag.Get("gcfg"), f.Tag)
-		if t.ident != "" {
-			return strings.EqualFold(t.ident, name)
-		}
-		return false
-	})
-	// Only if tag match fails, look up by field name
-	if !ok {
-		f, ok = v.Type().FieldByNameFunc(func(fieldName string) bool {
-			// TODO Implement proper recursive traversal
-			// to deal with potential field name collisions
-			defer func() {
-				recover()
-			}()
-			if !v.FieldByName(fieldName).CanSet() {
-				return false
-			}
-			res := strings.EqualFold(n, fieldName)
-			if res {
-				f, _ := v.Type().FieldByName(fieldName)
-				t := newMetadata(f.Tag.Get("gcfg"), f.Tag)
-				res = t.ident == ""
-			}
-			return res
-		})
+	if t.err == nil {
+		t.constraints.mincount, t.err = getIntTag(tag, "mincount", -1)
 	}
-	if !ok {
-		return reflect.Value{}, ixs, metadata{}
+	if t.err == nil {
+		t.constraints.maxcount, t.err = getIntTag(tag, "maxcount", -1)
 	}
-	r := v.FieldByName(f.Name)
-	if f, ok := v.Type().FieldByName(f.Name); ok {
-		ixs = f.Index
+	if t.err == nil {
+		t.err = parseTextConstraints(&t.constraints, tag)
 	}
-	return r, ixs, newMetadata(f.Tag.Get("gcfg"), f.Tag)
+	return t
 }
 
 type setter func(destp interface{}, blank bool, val string, t metadata) error
 
 var errUnsupportedType = fmt.Errorf("unsupported type")
 var errBlankUnsupported = fmt.Errorf("blank value not supported for type")
+var errInvalidSection = fmt.Errorf("invalid section")
+var errInvalidSubsection = fmt.Errorf("invalid subsection")
+var errInvalidVariable = fmt.Errorf("invalid variable")
 
 var setters = []setter{
 	typeSetter, textUnmarshalerSetter, kindSetter, scanSetter,
@@ -126,7 +97,8 @@ func textUnmarshalerSetter(d interface{}, blank bool, val string, t metadata) er
 		return errBlankUnsupported
 	}
 	if err := dtu.UnmarshalText([]byte(val)); err != nil { return err; }
-	return checkConstraints(d, t, unmarshalBoundary)
+	if err := checkText(val, t); err != nil { return err; }
+	return checkConstraints(d, t, unmarshalBoundary, nil)
 }
 
 func boolSetter(d interface{}, blank bool, val string, t metadata) error {
@@ -202,23 +174,37 @@ func stringSetter(d interface{}, blank bool, val string, t metadata) error {
 }
 
 var kindSetters = map[reflect.Kind]setter{
-	reflect.String:  stringSetter,
-	reflect.Bool:    boolSetter,
-	reflect.Int:     intSetter,
-	reflect.Int8:    intSetter,
-	reflect.Int16:   intSetter,
-	reflect.Int32:   intSetter,
-	reflect.Int64:   intSetter,
-	reflect.Uint:    intSetter,
-	reflect.Uint8:   intSetter,
-	reflect.Uint16:  intSetter,
-	reflect.Uint32:  intSetter,
-	reflect.Uint64:  intSetter,
-	reflect.Uintptr: intSetter,
+	reflect.String:     stringSetter,
+	reflect.Bool:       boolSetter,
+	reflect.Int:        intSetter,
+	reflect.Int8:       intSetter,
+	reflect.Int16:      intSetter,
+	reflect.Int32:      intSetter,
+	reflect.Int64:      intSetter,
+	reflect.Uint:       intSetter,
+	reflect.Uint8:      intSetter,
+	reflect.Uint16:     intSetter,
+	reflect.Uint32:     intSetter,
+	reflect.Uint64:     intSetter,
+	reflect.Uintptr:    intSetter,
+	reflect.Float32:    floatSetter,
+	reflect.Float64:    floatSetter,
+	reflect.Complex64:  complexSetter,
+	reflect.Complex128: complexSetter,
 }
 
+// typeSetters holds the setters for predefined types; parsers registered for
+// these types take precedence.
 var typeSetters = map[reflect.Type]setter{
 	reflect.TypeOf(big.Int{}): intSetter,
+	reflect.TypeOf(big.Rat{}): ratSetter,
+}
+
+// typeComparers holds the orderings of predefined types, used for checking
+// min and max bounds.
+var typeComparers = map[reflect.Type]comparer{
+	reflect.TypeOf(big.Int{}): bigComparer,
+	reflect.TypeOf(big.Rat{}): bigComparer,
 }
 
 func typeSetter(d interface{}, blank bool, val string, tt metadata) error {
@@ -227,6 +213,21 @@ func typeSetter(d interface{}, blank bool, val string, tt metadata) error {
 	if !ok {
 		return errUnsupportedType
 	}
+	return checkedSet(setter, typeComparers[t], d, blank, val, tt)
+}
+
+// mapSetter sets d using the setter for its type in m.
+func mapSetter(m map[reflect.Type]setter, d interface{}, blank bool, val string, tt metadata) error {
+	setter, ok := m[reflect.ValueOf(d).Type().Elem()]
+	if !ok {
+		return errUnsupportedType
+	}
+	return checkedSet(setter, nil, d, blank, val, tt)
+}
+
+// checkedSet sets d using setter and checks the constraints in tt, parsing
+// the bounds with setter and ordering values with cmp if not nil.
+func checkedSet(setter setter, cmp comparer, d interface{}, blank bool, val string, tt metadata) error {
 	if err := setter(d, blank, val, tt); err != nil { return err; }
 	boundaryGetter := func(d interface{}, val string) (*reflect.Value, error) {
 		if val == "" { return nil, nil; }
@@ -235,7 +236,7 @@ func typeSetter(d interface{}, blank bool, val string, tt metadata) error {
 		if err := setter(r.Interface(), false, val, tt); err != nil { return nil, err; }
 		return r, nil
 	}
-	return checkConstraints(d, tt, boundaryGetter)
+	return checkConstraints(d, tt, boundaryGetter, cmp)
 }
 
 func kindSetter(d interface{}, blank bool, val string, t metadata) error {
@@ -252,7 +253,7 @@ func kindSetter(d interface{}, blank bool, val string, t metadata) error {
 		if err := setter(r.Interface(), false, val, t); err != nil { return nil, err; }
 		return r, nil
 	}
-	return checkConstraints(d, t, boundaryGetter)
+	return checkConstraints(d, t, boundaryGetter, nil)
 }
 
 func scanSetter(d interface{}, blank bool, val string, t metadata) error {
@@ -262,19 +263,20 @@ func scanSetter(d interface{}, blank bool, val string, t metadata) error {
 	if err := types.ScanFully(d, val, 'v'); err != nil {
 		return err
 	}
-	return checkConstraints(d, t, scanBoundary)
+	return checkConstraints(d, t, scanBoundary, nil)
 }
 
-func set(cfg interface{}, sect, sub, name string, blank bool, value string) error {
+func (rs *readState) set(cfg interface{}, sect, sub, name string, blank bool, value string) error {
 	vPCfg := reflect.ValueOf(cfg)
 	if vPCfg.Kind() != reflect.Ptr || vPCfg.Elem().Kind() != reflect.Struct {
 		panic(fmt.Errorf("config must be a pointer to a struct"))
 	}
 	vCfg := vPCfg.Elem()
-	vSect, _, _ := fieldFold(vCfg, sect)
+	vSect, fSect := fieldFold(vCfg, sect, rs.sectionAlloc(vCfg))
 	if !vSect.IsValid() {
-		return fmt.Errorf("invalid section: section %q", sect)
+		return newError(UnknownSection, sect, "", "", errInvalidSection)
 	}
+	sk := sectKey{fieldKeyOf(vSect), sub}
 	if vSect.Kind() == reflect.Map {
 		vst := vSect.Type()
 		if vst.Key().Kind() != reflect.String ||
@@ -286,39 +288,114 @@ func set(cfg interface{}, sect, sub, name string, blank bool, value string) erro
 		if vSect.IsNil() {
 			vSect.Set(reflect.MakeMap(vst))
 		}
+		if rs.order != nil {
+			rs.order.add(fSect.name, sub)
+		}
 		k := reflect.ValueOf(sub)
 		pv := vSect.MapIndex(k)
 		if !pv.IsValid() {
 			vType := vSect.Type().Elem().Elem()
 			pv = reflect.New(vType)
 			vSect.SetMapIndex(k, pv)
+			rs.setDefaults(pv.Elem(), sect, sub)
 		}
 		vSect = pv.Elem()
 	} else if vSect.Kind() == reflect.Ptr && (vSect.IsNil() || vSect.Elem().Kind() == reflect.Struct) {
 		if vSect.IsNil() {
 			vSect.Set(reflect.New(vSect.Type().Elem()))
+			rs.setDefaults(vSect.Elem(), sect, sub)
 		}
 		vSect = vSect.Elem()
 	} else if vSect.Kind() != reflect.Struct {
 		panic(fmt.Errorf("field for section must be a map or a struct: "+
 			"section %q", sect))
 	} else if sub != "" {
-		return fmt.Errorf("invalid subsection: "+
-			"section %q subsection %q", sect, sub)
+		return newError(UnknownSection, sect, sub, "", errInvalidSubsection)
 	}
-	vVar, ixs, t := fieldFold(vSect, name)
+	vVar, f := fieldFold(vSect, name, rs.varAlloc(vSect, sect, sub))
 	if !vVar.IsValid() {
-		return fmt.Errorf("invalid variable: "+
-			"section %q subsection %q variable %q", sect, sub, name)
+		if vRest := restField(vSect, rs.varAlloc(vSect, sect, sub)); vRest.IsValid() {
+			setRest(vRest, name, blank, value)
+			rs.sections[sk] = true
+			return nil
+		}
+		return valueError(&Error{Kind: UnknownVariable, Err: errInvalidVariable}, sect, sub, name, value)
 	}
+	t := f.meta
 	if t.err != nil {
-		return fmt.Errorf("%s: "+
-			"section %q subsection %q variable %q", t.err, sect, sub, name)
+		return newError(TypeError, sect, sub, name, t.err)
+	}
+	if isMulti(vVar) && rs.defaulted[fieldKeyOf(vVar)] {
+		// values read replace the default values
+		vVar.Set(reflect.Zero(vVar.Type()))
+		delete(rs.defaulted, fieldKeyOf(vVar))
+	}
+	multi := isMulti(vVar)
+	if err := setValue(vVar, t, blank, value, rs.setters()); err != nil {
+		e := valueError(err, sect, sub, name, value)
+		if multi {
+			e.Err = valueNumError(vVar.Len()+1, e.Err)
+		}
+		return e
+	}
+	fk := fieldKeyOf(vVar)
+	rs.sections[sk] = true
+	if multi && blank {
+		// a reset without values does not count as setting the variable
+		delete(rs.vars, fk)
+		delete(rs.valuePos, fk)
+	} else {
+		rs.vars[fk] = true
+		if multi {
+			rs.valuePos[fk] = append(rs.valuePos[fk], rs.pos)
+		}
 	}
+	if len(t.callback) > 0 && !(blank && isMulti(vVar)) {
+		// the method is called on the struct declaring the field
+		vs := fieldByIndex(vSect, f.index[:len(f.index)-1], nil)
+		d := Definition{Pos: rs.pos, Section: sect, Subsection: sub,
+			Variable: name, Value: value, Blank: blank}
+		if err := callback(vs, t.callback, d); err != nil {
+			return valueError(err, sect, sub, name, value)
+		}
+	}
+	return nil
+}
+
+// isMulti reports whether vVar is a multi-valued variable.
+func isMulti(vVar reflect.Value) bool {
+	return vVar.Type().Name() == "" && vVar.Kind() == reflect.Slice
+}
+
+// setters returns the setters to use: those for the type parsers given as
+// options, for the parsers in the registry of the read, and the predefined
+// ones.
+func (rs *readState) setters() []setter {
+	if rs.ss != nil {
+		return rs.ss
+	}
+	if len(rs.typeSetters) > 0 {
+		rs.ss = append(rs.ss, func(d interface{}, blank bool, val string, t metadata) error {
+			return mapSetter(rs.typeSetters, d, blank, val, t)
+		})
+	}
+	reg := rs.registry
+	if reg == nil {
+		reg = DefaultRegistry
+	}
+	rs.ss = append(rs.ss, reg.set)
+	rs.ss = append(rs.ss, setters...)
+	return rs.ss
+}
+
+// setValue sets vVar to the value parsed from value using the first setter in
+// ss that supports its type, or for a multi-valued variable, appends the
+// parsed value.
+func setValue(vVar reflect.Value, t metadata, blank bool, value string, ss []setter) error {
 	// vVal is either single-valued var, or newly allocated value within multi-valued var
 	var vVal reflect.Value
 	// multi-value if unnamed slice type
-	isMulti := vVar.Type().Name() == "" && vVar.Kind() == reflect.Slice
+	isMulti := isMulti(vVar)
 	if isMulti && blank {
 		vVar.Set(reflect.Zero(vVar.Type()))
 		return nil
@@ -342,7 +419,7 @@ func set(cfg interface{}, sect, sub, name string, blank bool, value string) erro
 	}
 	vAddrI := vAddr.Interface()
 	err, ok := error(nil), false
-	for _, s := range setters {
+	for _, s := range ss {
 		err = s(vAddrI, blank, value, t)
 		if err == nil {
 			ok = true
@@ -362,45 +439,70 @@ func set(cfg interface{}, sect, sub, name string, blank bool, value string) erro
 	if isMulti { // append if multi-valued
 		vVar.Set(reflect.Append(vVar, vVal))
 	}
-	if len(t.callback) > 0 {
-		vs := vSect
-		if len(ixs) > 0 {
-			vs = vSect.FieldByIndex(ixs[1:])
-		}
-		vs = vs.Addr()
-		if m := vs.MethodByName(t.callback); m.IsValid() {
-			m.Call([]reflect.Value{})
+	return nil
+}
+
+// valueError returns err as an *Error for the variable identified by sect,
+// sub and name. Errors not already classified by the setters are parse errors.
+func valueError(err error, sect, sub, name, value string) *Error {
+	e, ok := err.(*Error)
+	if !ok {
+		k := ParseError
+		if err == errBlankUnsupported {
+			k = BlankUnsupported
 		}
+		e = &Error{Kind: k, Err: err}
 	}
-	return nil
+	e.Section, e.Subsection, e.Variable, e.Value = sect, sub, name, value
+	return e
 }
 
+// A TypeParser parses the value of a variable of a given type; blank is set
+// for a blank value. It returns the parsed value, or a pointer to it.
 type TypeParser func(blank bool, val string) (interface{}, error)
 
-// Registers type parser function.
+// Registers type parser function in DefaultRegistry.
 func RegisterTypeParser(tgtType reflect.Type, typeParser TypeParser) error {
-	typeSetters[tgtType] = func(d interface{}, blank bool, val string, t metadata) error {
+	DefaultRegistry.Register(tgtType, typeParser)
+	return nil
+}
+
+// UseTypeParser makes the read parse values of type tgtType with typeParser,
+// in preference to the parsers in the registry used and without affecting
+// other reads.
+func UseTypeParser(tgtType reflect.Type, typeParser TypeParser) ReadOption {
+	return useSetter(tgtType, typeParserSetter(typeParser))
+}
+
+func useSetter(tgtType reflect.Type, s setter) ReadOption {
+	return func(rs *readState) {
+		if rs.typeSetters == nil {
+			rs.typeSetters = map[reflect.Type]setter{}
+		}
+		rs.typeSetters[tgtType] = s
+	}
+}
+
+// typeParserSetter returns the setter using typeParser. The value returned
+// by typeParser is set if it is assignable to the variable, or else the value
+// it points to.
+func typeParserSetter(typeParser TypeParser) setter {
+	return func(d interface{}, blank bool, val string, t metadata) error {
 		v, err := typeParser(blank, val)
-		if err == nil {
-			sv := reflect.ValueOf(v)
-			tv := reflect.ValueOf(d)
-			if tv.CanSet() {
-				// TODO properly enumerate over Kind
-				if sv.Kind() < reflect.Array {
-					tv.Set(reflect.ValueOf(v))
-				} else {
-					tv.Set(reflect.ValueOf(v).Elem())
-				}
-			} else {
-				// TODO properly enumerate over Kind
-				if sv.Kind() < reflect.Array {
-					tv.Elem().Set(reflect.ValueOf(v))
-				} else {
-					tv.Elem().Set(reflect.ValueOf(v).Elem())
-				}
-			}
+		if err != nil {
+			return err
 		}
-		return err
+		dv, sv := reflect.ValueOf(d).Elem(), reflect.ValueOf(v)
+		switch {
+		case !sv.IsValid():
+			dv.Set(reflect.Zero(dv.Type()))
+		case sv.Type().AssignableTo(dv.Type()):
+			dv.Set(sv)
+		case sv.Kind() == reflect.Ptr && !sv.IsNil() && sv.Type().Elem().AssignableTo(dv.Type()):
+			dv.Set(sv.Elem())
+		default:
+			return fmt.Errorf("type parser returned %v for %v", sv.Type(), dv.Type())
+		}
+		return nil
 	}
-	return nil
 }
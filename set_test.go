@@ -1,6 +1,7 @@
 package gcfg
 
 import (
+	"fmt"
 	"testing"
 	"time"
 	"reflect"
@@ -34,7 +35,7 @@ type cBoundsTypes1 struct {
 type cCbTypes1 struct {
 	Int1 int `gcfg:",cb=Cb"`
 	Int2 int
-	Int3 int `gcfg:",cb=Cbb"`
+	Int3 int `gcfg:",cb=Cbe"`
 	Int4 int `gcfg:",cb=Cb"`
 	int5 int
 }
@@ -43,6 +44,14 @@ func (c *cCbTypes1) Cb() {
 	c.int5++
 }
 
+func (c *cCbTypes1) Cbe(d Definition) error {
+	if c.Int3 < 0 {
+		return fmt.Errorf("negative %s: %s", d.Variable, d.Value)
+	}
+	c.int5++
+	return nil
+}
+
 type cRegTypes struct {
 	Reg_Types_1    cRegTypes1
 	Bounds_Types_1 cBoundsTypes1
@@ -194,7 +203,8 @@ func TestCallback(t *testing.T) {
 	for _, tt := range []stTestCase{
 		{"[cb-types-1]\nint1=1", &cRegTypes{Cb_Types_1: cCbTypes1{Int1: 1, Int2: 0, Int3: 0, Int4: 0, int5: 1}}, true},
 		{"[cb-types-1]\nint2=1", &cRegTypes{Cb_Types_1: cCbTypes1{Int1: 0, Int2: 1, Int3: 0, Int4: 0, int5: 0}}, true},
-		{"[cb-types-1]\nint3=1", &cRegTypes{Cb_Types_1: cCbTypes1{Int1: 0, Int2: 0, Int3: 1, Int4: 0, int5: 0}}, true}, // quietly fail
+		{"[cb-types-1]\nint3=1", &cRegTypes{Cb_Types_1: cCbTypes1{Int1: 0, Int2: 0, Int3: 1, Int4: 0, int5: 1}}, true},
+		{"[cb-types-1]\nint3=-1", &cRegTypes{Cb_Types_1: cCbTypes1{Int1: 0, Int2: 0, Int3: -1, Int4: 0, int5: 0}}, false},
 		{"[cb-types-1]\nint4=1", &cRegTypes{Cb_Types_1: cCbTypes1{Int1: 0, Int2: 0, Int3: 0, Int4: 1, int5: 1}}, true},
 		{"[cb-types-1]\nint1=1\nint4=1", &cRegTypes{Cb_Types_1: cCbTypes1{Int1: 1, Int2: 0, Int3: 0, Int4: 1, int5: 2}}, true},
 	} {
@@ -0,0 +1,169 @@
+package gcfg
+
+import (
+	"errors"
+	"io"
+	"io/ioutil"
+	"os"
+)
+
+// A Source provides gcfg formatted data to ReadSourcesInto.
+type Source interface {
+	// readInto reads the data provided by the source into config. Errors
+	// in the data are recorded in rs; a non-nil error stops the read.
+	readInto(rs *readState, config interface{}) error
+}
+
+type fileSource struct {
+	filename string
+	optional bool
+}
+
+// FileSource returns a Source reading the file filename.
+func FileSource(filename string) Source {
+	return fileSource{filename: filename}
+}
+
+// OptionalFileSource returns a Source reading the file filename if it exists.
+// A missing file provides no data rather than an error.
+func OptionalFileSource(filename string) Source {
+	return fileSource{filename: filename, optional: true}
+}
+
+func (s fileSource) readInto(rs *readState, config interface{}) error {
+	src, err := rs.readFile(s.filename)
+	if err != nil {
+		if s.optional && os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	return rs.readSource(config, s.filename, src)
+}
+
+type readerSource struct {
+	name   string
+	reader io.Reader
+}
+
+// ReaderSource returns a Source reading from reader. Positions in errors
+// refer to name.
+func ReaderSource(name string, reader io.Reader) Source {
+	return readerSource{name, reader}
+}
+
+func (s readerSource) readInto(rs *readState, config interface{}) error {
+	src, err := rs.readAll(s.reader)
+	if err != nil {
+		return err
+	}
+	return rs.readSource(config, s.name, src)
+}
+
+type stringSource struct {
+	name string
+	str  string
+}
+
+// StringSource returns a Source reading from str. Positions in errors refer
+// to name.
+func StringSource(name, str string) Source {
+	return stringSource{name, str}
+}
+
+func (s stringSource) readInto(rs *readState, config interface{}) error {
+	if rs.maxSize > 0 && int64(len(s.str)) > rs.maxSize {
+		return ErrTooLarge
+	}
+	return rs.readSource(config, s.name, []byte(s.str))
+}
+
+// ErrTooLarge is returned when an input exceeds the size limit set with
+// MaxSize.
+var ErrTooLarge = errors.New("gcfg: input too large")
+
+// MaxSize limits the size of each input of the read, including included
+// files, to n bytes. Reading a larger input fails with ErrTooLarge; for an
+// included file, it is reported as an IncludeError.
+func MaxSize(n int64) ReadOption {
+	return func(rs *readState) { rs.maxSize = n }
+}
+
+// SourceName sets the name of inputs without file name, such as those read
+// by ReadInto and Decoder, for positions in errors and for resolving relative
+// include paths.
+func SourceName(name string) ReadOption {
+	return func(rs *readState) { rs.name = name }
+}
+
+// readAll reads r to EOF, within the size limit.
+func (rs *readState) readAll(r io.Reader) ([]byte, error) {
+	if rs.maxSize <= 0 {
+		return ioutil.ReadAll(r)
+	}
+	src, err := ioutil.ReadAll(io.LimitReader(r, rs.maxSize+1))
+	if err == nil && int64(len(src)) > rs.maxSize {
+		err = ErrTooLarge
+	}
+	return src, err
+}
+
+// readFile reads the file filename, within the size limit.
+func (rs *readState) readFile(filename string) ([]byte, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return rs.readAll(f)
+}
+
+// ReadSourcesInto reads gcfg formatted data from each source in turn and sets
+// the values into the corresponding fields in config.
+//
+// Sources are applied in order, so values from later sources take precedence
+// over values from earlier ones, as for git's system, global and local
+// configuration files. Values of multi-valued variables accumulate across
+// sources; a blank value discards the values set by earlier sources.
+// Subsections with the same name in several sources are merged into a single
+// map entry.
+//
+// All sources share a single token.FileSet, so that error positions name the
+// source they refer to. Errors reading a source (rather than parsing its data)
+// are returned as is, and stop the read even with the CollectErrors option.
+func ReadSourcesInto(config interface{}, sources []Source, opts ...ReadOption) error {
+	rs := newReadState(opts)
+	if rs.checkType(config); rs.errs.Len() > 0 {
+		return rs.err()
+	}
+	rs.setConfigDefaults(config)
+	if rs.errs.Len() > 0 && !rs.collect {
+		return rs.err()
+	}
+	for _, s := range sources {
+		if err := s.readInto(rs, config); err != nil {
+			return err
+		}
+	}
+	if rs.envPrefix != "" {
+		if err := EnvSource(rs.envPrefix).readInto(rs, config); err != nil {
+			return err
+		}
+	}
+	rs.checkRequired(config)
+	if rs.errs.Len() == 0 {
+		rs.validate(config)
+	}
+	return rs.err()
+}
+
+// ReadFilesInto reads gcfg formatted data from the files filenames in order
+// and sets the values into the corresponding fields in config, as described
+// for ReadSourcesInto.
+func ReadFilesInto(config interface{}, filenames []string, opts ...ReadOption) error {
+	sources := make([]Source, len(filenames))
+	for i, filename := range filenames {
+		sources[i] = FileSource(filename)
+	}
+	return ReadSourcesInto(config, sources, opts...)
+}
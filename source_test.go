@@ -0,0 +1,92 @@
+package gcfg
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+import (
+	"github.com/baobabus/gcfg/scanner"
+)
+
+type cSources struct {
+	Section cSourcesS1
+	Sub     map[string]*cSourcesS1
+}
+type cSourcesS1 struct {
+	Name  string
+	Int   int
+	Multi []string
+}
+
+func TestReadSourcesInto(t *testing.T) {
+	for i, tt := range []struct {
+		srcs []string
+		exp  *cSources
+	}{
+		// later sources take precedence
+		{[]string{"[section]\nname=a\nint=1", "[section]\nname=b"},
+			&cSources{Section: cSourcesS1{Name: "b", Int: 1}}},
+		// multi-valued variables accumulate, blank resets
+		{[]string{"[section]\nmulti=a", "[section]\nmulti=b"},
+			&cSources{Section: cSourcesS1{Multi: []string{"a", "b"}}}},
+		{[]string{"[section]\nmulti=a", "[section]\nmulti\nmulti=b"},
+			&cSources{Section: cSourcesS1{Multi: []string{"b"}}}},
+		// subsections are merged
+		{[]string{"[sub \"a\"]\nname=a\nint=1", "[sub \"a\"]\nint=2\n[sub \"b\"]\nname=b"},
+			&cSources{Sub: map[string]*cSourcesS1{"a": {Name: "a", Int: 2}, "b": {Name: "b"}}}},
+	} {
+		var srcs []Source
+		for j, s := range tt.srcs {
+			srcs = append(srcs, StringSource(fmt.Sprintf("src%d", j), s))
+		}
+		res := &cSources{}
+		if err := ReadSourcesInto(res, srcs); err != nil {
+			t.Errorf("%d fail: got error %v, wanted ok", i, err)
+		} else if !reflect.DeepEqual(res, tt.exp) {
+			t.Errorf("%d fail: got value %#v, wanted value %#v", i, res, tt.exp)
+		}
+	}
+}
+
+func TestReadSourcesIntoErrors(t *testing.T) {
+	srcs := []Source{
+		StringSource("a", "[section]\nint=x"),
+		ReaderSource("b", strings.NewReader("[section]\nname=b\nnonexistent=1")),
+	}
+	res := &cSources{}
+	err := ReadSourcesInto(res, srcs)
+	if e, ok := err.(*Error); !ok || e.Pos.Filename != "a" || e.Pos.Line != 2 {
+		t.Errorf("got error %#v, wanted *Error at a:2", err)
+	}
+	res = &cSources{}
+	err = ReadSourcesInto(res, srcs, CollectErrors())
+	errs, ok := err.(scanner.ErrorList)
+	if !ok || len(errs) != 2 ||
+		errs[0].Pos.Filename != "a" || errs[1].Pos.Filename != "b" || errs[1].Pos.Line != 3 {
+		t.Errorf("got error %#v, wanted errors at a:2 and b:3", err)
+	}
+	if res.Section.Name != "b" {
+		t.Errorf("got name %q, wanted %q", res.Section.Name, "b")
+	}
+}
+
+func TestReadFilesInto(t *testing.T) {
+	res := &struct{ Section struct{ Name string } }{}
+	err := ReadFilesInto(res, []string{"testdata/nonexistent.gcfg"})
+	if err == nil {
+		t.Errorf("got ok, wanted error for missing file")
+	}
+	err = ReadSourcesInto(res, []Source{
+		OptionalFileSource("testdata/nonexistent.gcfg"),
+		FileSource("testdata/gcfg_test.gcfg"),
+	})
+	if err != nil {
+		t.Errorf("got error %v, wanted ok", err)
+	}
+	if res.Section.Name != "value" {
+		t.Errorf("got %q, wanted %q", res.Section.Name, "value")
+	}
+}
@@ -0,0 +1,95 @@
+package gcfg
+
+import (
+	"reflect"
+	"strings"
+)
+
+import (
+	"github.com/baobabus/gcfg/token"
+)
+
+// WarnUnknown makes the read call warn for each variable in an unknown section
+// or subsection, and for each unknown variable, instead of failing. The
+// variables are otherwise ignored. warn is passed the error that would have
+// been reported, of kind UnknownSection or UnknownVariable, at the position of
+// the variable.
+//
+// This allows older programs to read configuration written for newer ones.
+func WarnUnknown(warn func(e *Error)) ReadOption {
+	return func(rs *readState) {
+		rs.unknown = func(e *Error, blank bool) { warn(e) }
+	}
+}
+
+// CollectUnknown makes the read add each variable in an unknown section or
+// subsection, and each unknown variable, to extras instead of failing.
+func CollectUnknown(extras *Extras) ReadOption {
+	return func(rs *readState) {
+		rs.unknown = func(e *Error, blank bool) {
+			*extras = append(*extras, Extra{
+				Pos:        e.Pos,
+				Section:    e.Section,
+				Subsection: e.Subsection,
+				Variable:   e.Variable,
+				Blank:      blank,
+				Value:      e.Value,
+			})
+		}
+	}
+}
+
+// Extra is a variable definition that does not correspond to a field of the
+// config, as collected by CollectUnknown.
+type Extra struct {
+	Pos        token.Position
+	Section    string
+	Subsection string
+	Variable   string
+	Blank      bool   // variable without equals sign and value
+	Value      string // value, if not blank
+}
+
+// Extras is a list of variable definitions, in the order read.
+type Extras []Extra
+
+// Get returns the values of the variable name in section sect and
+// subsection sub, ignoring case in section and variable names.
+func (x Extras) Get(sect, sub, name string) []string {
+	var vals []string
+	for _, e := range x {
+		if strings.EqualFold(e.Section, sect) && e.Subsection == sub &&
+			strings.EqualFold(e.Variable, name) {
+			vals = append(vals, e.Value)
+		}
+	}
+	return vals
+}
+
+var restType = reflect.TypeOf(map[string][]string{})
+
+// restField returns the field of section struct vSect tagged with the "rest"
+// option, allocating nil embedded pointers on the way to it as fieldByIndex
+// does with alloc, or the zero Value if there is none.
+func restField(vSect reflect.Value, alloc func(prefix []int)) reflect.Value {
+	if f := structInfoOf(vSect.Type()).rest; f != nil {
+		return fieldByIndex(vSect, f.index, alloc)
+	}
+	return reflect.Value{}
+}
+
+// setRest adds the variable name to the catch-all field vRest, with the name
+// converted to lower case. As for multi-valued variables, a blank value
+// discards the values added before.
+func setRest(vRest reflect.Value, name string, blank bool, value string) {
+	if vRest.IsNil() {
+		vRest.Set(reflect.MakeMap(restType))
+	}
+	m := vRest.Interface().(map[string][]string)
+	name = strings.ToLower(name)
+	if blank {
+		m[name] = []string{}
+		return
+	}
+	m[name] = append(m[name], value)
+}
@@ -0,0 +1,107 @@
+package gcfg
+
+import (
+	"reflect"
+	"testing"
+)
+
+import (
+	"github.com/baobabus/gcfg/token"
+)
+
+type cUnknown struct {
+	Section cUnknownS1
+	Sub     map[string]*cUnknownS1
+	Rest    cUnknownS2
+}
+type cUnknownS1 struct{ Name string }
+type cUnknownS2 struct {
+	Name  string
+	Other map[string][]string `gcfg:",rest"`
+}
+
+const unknownCfg = `[section]
+name=a
+new=1
+[newsection "x"]
+flag
+[section "x"]
+name=b
+[rest]
+name=c
+Extra=1
+extra=2
+other=3
+flag
+`
+
+func TestUnknownError(t *testing.T) {
+	err := ReadStringInto(&cUnknown{}, unknownCfg)
+	if e, ok := err.(*Error); !ok || e.Kind != UnknownVariable || e.Pos.Line != 3 {
+		t.Errorf("got error %v, wanted unknown variable at line 3", err)
+	}
+}
+
+func TestWarnUnknown(t *testing.T) {
+	var warnings []*Error
+	res := &cUnknown{}
+	err := ReadStringInto(res, unknownCfg, WarnUnknown(func(e *Error) {
+		warnings = append(warnings, e)
+	}))
+	if err != nil {
+		t.Errorf("got error %v, wanted ok", err)
+	}
+	var lines []int
+	for _, e := range warnings {
+		lines = append(lines, e.Pos.Line)
+	}
+	// variables in unknown sections are at their own lines
+	if !reflect.DeepEqual(lines, []int{3, 5, 7}) {
+		t.Errorf("got warnings on lines %v, wanted [3 5 7]: %v", lines, warnings)
+	}
+	exp := &cUnknown{Section: cUnknownS1{Name: "a"}, Rest: cUnknownS2{Name: "c",
+		Other: map[string][]string{"extra": {"1", "2"}, "other": {"3"}, "flag": {}}}}
+	if !reflect.DeepEqual(res, exp) {
+		t.Errorf("got value %#v, wanted value %#v", res, exp)
+	}
+}
+
+func TestCollectUnknown(t *testing.T) {
+	var extras Extras
+	err := ReadStringInto(&cUnknown{}, unknownCfg, CollectUnknown(&extras))
+	if err != nil {
+		t.Errorf("got error %v, wanted ok", err)
+	}
+	exp := Extras{
+		{Section: "section", Variable: "new", Value: "1"},
+		{Section: "newsection", Subsection: "x", Variable: "flag", Blank: true},
+		{Section: "section", Subsection: "x", Variable: "name", Value: "b"},
+	}
+	for i, line := range []int{3, 5, 7} {
+		if i < len(extras) && extras[i].Pos.Line != line {
+			t.Errorf("got extra %d at line %d, wanted line %d", i, extras[i].Pos.Line, line)
+		}
+	}
+	for i := range extras {
+		extras[i].Pos = token.Position{}
+	}
+	if !reflect.DeepEqual(extras, exp) {
+		t.Errorf("got extras %#v, wanted %#v", extras, exp)
+	}
+	if v := extras.Get("SECTION", "", "New"); !reflect.DeepEqual(v, []string{"1"}) {
+		t.Errorf("got %v, wanted [1]", v)
+	}
+}
+
+func TestRestInvalidType(t *testing.T) {
+	res := &struct {
+		Section struct {
+			Name  string
+			Other map[string]string `gcfg:",rest"`
+		}
+	}{}
+	err := ReadStringInto(res, "[section]\nname=a")
+	if e, ok := err.(*Error); !ok || e.Kind != TypeError || e.Variable != "other" {
+		t.Errorf("got error %#v, wanted type error for variable other", err)
+	}
+}
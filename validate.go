@@ -0,0 +1,116 @@
+package gcfg
+
+import (
+	"reflect"
+	"sort"
+)
+
+// Validator is implemented by section structs and config structs that check
+// their values once read. Validate is called with a pointer receiver if the
+// struct implements it through a pointer.
+type Validator interface {
+	Validate() error
+}
+
+// ValidateConfig makes the read call fn with the config read into once it is
+// complete, for checks involving several sections. Hooks are called in the
+// order given, after the Validate methods of the sections and the config,
+// unless the read failed.
+func ValidateConfig(fn func(config interface{}) error) ReadOption {
+	return func(rs *readState) { rs.validators = append(rs.validators, fn) }
+}
+
+// validate calls the Validate methods of the sections and subsections of
+// config, in the order of the section fields and of the subsection names,
+// then that of config itself and the hooks given with ValidateConfig.
+// Sections held by nil pointers are not validated.
+//
+// An error returned is reported as a ValidationError for the section or
+// subsection, at the position of its first header in the input; an *Error
+// returned is reported as is, with the section, subsection and position
+// filled in where missing.
+func (rs *readState) validate(config interface{}) {
+	vc := reflect.ValueOf(config).Elem()
+	for _, f := range structInfoOf(vc.Type()).fields {
+		vSect := fieldByIndex(vc, f.index, nil)
+		switch {
+		case !vSect.IsValid():
+			continue
+		case vSect.Kind() == reflect.Map:
+			if vSect.Type().Elem().Kind() != reflect.Ptr ||
+				vSect.Type().Elem().Elem().Kind() != reflect.Struct {
+				continue
+			}
+			var subs []string
+			for _, k := range vSect.MapKeys() {
+				subs = append(subs, k.String())
+			}
+			sort.Strings(subs)
+			fk := fieldKeyOf(vSect)
+			for _, sub := range subs {
+				pv := vSect.MapIndex(reflect.ValueOf(sub))
+				if pv.IsNil() {
+					continue
+				}
+				if !rs.validateValue(pv, f.name, sub, sectKey{fk, sub}) {
+					return
+				}
+			}
+		case vSect.Kind() == reflect.Struct:
+			if !rs.validateValue(vSect.Addr(), f.name, "", sectKey{fieldKeyOf(vSect), ""}) {
+				return
+			}
+		case vSect.Kind() == reflect.Ptr && vSect.Type().Elem().Kind() == reflect.Struct:
+			if vSect.IsNil() {
+				continue
+			}
+			if !rs.validateValue(vSect, f.name, "", sectKey{fieldKeyOf(vSect), ""}) {
+				return
+			}
+		}
+	}
+	if !rs.validateValue(vc.Addr(), "", "", sectKey{}) {
+		return
+	}
+	for _, fn := range rs.validators {
+		if err := fn(config); err != nil {
+			rs.report(validationError(err, "", ""))
+			if !rs.collect {
+				return
+			}
+		}
+	}
+}
+
+// validateValue calls the Validate method of the struct pv points to, if
+// any, and reports the error it returns for section sect and subsection sub
+// with header k. It returns false if the read is to stop.
+func (rs *readState) validateValue(pv reflect.Value, sect, sub string, k sectKey) bool {
+	v, ok := pv.Interface().(Validator)
+	if !ok {
+		return true
+	}
+	err := v.Validate()
+	if err == nil {
+		return true
+	}
+	e := validationError(err, sect, sub)
+	if !e.Pos.IsValid() {
+		e.Pos = rs.sectionPos[k]
+	}
+	rs.report(e)
+	return rs.collect
+}
+
+// validationError returns err as an *Error for section sect and subsection
+// sub.
+func validationError(err error, sect, sub string) *Error {
+	e, ok := err.(*Error)
+	if !ok {
+		return newError(ValidationError, sect, sub, "", err)
+	}
+	if e.Section == "" {
+		e.Section, e.Subsection = sect, sub
+	}
+	return e
+}
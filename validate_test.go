@@ -0,0 +1,87 @@
+package gcfg
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+import (
+	"github.com/baobabus/gcfg/scanner"
+)
+
+type cValidateRange struct {
+	Min, Max int
+}
+
+func (r *cValidateRange) Validate() error {
+	if r.Min > r.Max {
+		return fmt.Errorf("min %d greater than max %d", r.Min, r.Max)
+	}
+	return nil
+}
+
+type cValidateName struct {
+	Name string
+}
+
+func (n cValidateName) Validate() error {
+	if n.Name == "" {
+		return &Error{Kind: MissingError, Variable: "name", Err: errMissingVariable}
+	}
+	return nil
+}
+
+type cValidate struct {
+	Range cValidateRange
+	Named map[string]*cValidateName
+	Ptr   *cValidateRange
+}
+
+func (c *cValidate) Validate() error {
+	if len(c.Named) > 0 && c.Ptr == nil {
+		return fmt.Errorf("named sections require section ptr")
+	}
+	return nil
+}
+
+func TestValidate(t *testing.T) {
+	hook := ValidateConfig(func(config interface{}) error {
+		if c := config.(*cValidate); c.Ptr != nil && c.Ptr.Max > c.Range.Max {
+			return fmt.Errorf("ptr exceeds range")
+		}
+		return nil
+	})
+	for i, tt := range []struct {
+		in   string
+		opts []ReadOption
+		kind ErrorKind
+		err  string
+	}{
+		{"[range]\nmin=1\nmax=2", nil, 0, ""},
+		{"\n[range]\nmin=3\nmax=2", nil, ValidationError, "2:1: min 3 greater than max 2: section \"range\""},
+		{"[range]\n[ptr]\nmin=3\n[ptr]\nmax=2", nil, ValidationError, "2:1: min 3 greater than max 2: section \"ptr\""},
+		{"[ptr]\nmin=0\n[named \"a\"]\nname=x\n[named \"b\"]\nname=", nil, MissingError,
+			"5:1: missing variable: section \"named\" subsection \"b\" variable \"name\""},
+		{"[named \"a\"]\nname=x", nil, ValidationError, "named sections require section ptr"},
+		{"[ptr]\nmax=1", []ReadOption{hook}, ValidationError, "ptr exceeds range"},
+		{"[ptr]\nmax=0", []ReadOption{hook}, 0, ""},
+		// not validated after other errors
+		{"[range]\nmin=3\nmax=x", nil, ParseError, "variable \"max\""},
+	} {
+		err := ReadStringInto(&cValidate{}, tt.in, tt.opts...)
+		switch {
+		case tt.err == "" && err != nil:
+			t.Errorf("%d: unexpected error: %v", i, err)
+		case tt.err != "" && (err == nil || !strings.HasSuffix(err.Error(), tt.err)):
+			t.Errorf("%d: got error %v, want %q", i, err, tt.err)
+		case err != nil && err.(*Error).Kind != tt.kind:
+			t.Errorf("%d: got kind %v, want %v", i, err.(*Error).Kind, tt.kind)
+		}
+	}
+	// all validation errors are collected
+	err := ReadStringInto(&cValidate{}, "[range]\nmin=3\n[named \"a\"]\nname=", CollectErrors(), hook)
+	if el, ok := err.(scanner.ErrorList); !ok || len(el) != 3 {
+		t.Errorf("got %v, want 3 errors", err)
+	}
+}
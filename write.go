@@ -1,35 +1,224 @@
 package gcfg
 
 import (
+	"bytes"
 	"fmt"
 	"io"
+	"math/big"
 	"reflect"
-	"strings"
+	"sort"
+	"strconv"
+	"sync"
 )
 
-func writeItem(v reflect.Value, name string, w io.Writer) error {
-	vi := v.Interface()
-	z := reflect.Zero(v.Type())
-	if !reflect.DeepEqual(z.Interface(), vi) {
-		if p, ok := typeFormatters[v.Type()]; ok {
-			vi = p(vi)
-		} else {
-			if _, ok := vi.(fmt.Stringer); !ok && v.CanAddr() {
-				vr := v.Addr()
-				vri := vr.Interface()
-				if _, okk := vri.(fmt.Stringer); okk {
-					vi = vri
-				}
-			}
+import (
+	"github.com/baobabus/gcfg/ast"
+	"github.com/baobabus/gcfg/types"
+)
+
+// A WriteOption configures a single write operation.
+type WriteOption func(*writeState)
+
+// writeState holds the state of a single write operation.
+type writeState struct {
+	w io.Writer
+	// orders the subsection names of a section; nil for sorted order
+	order func(sect string, subs []string) []string
+	// write single-valued variables set to the zero value of their type
+	zero bool
+	// write single-valued true bools as blank values
+	blankBools bool
+	// formatters given as options
+	typeFormatters map[reflect.Type]formatter
+}
+
+// EmitZeroValues makes Write write single-valued variables that hold the zero
+// value of their type, such as false, 0 and the empty string. By default, such
+// variables are left out.
+func EmitZeroValues() WriteOption {
+	return func(ws *writeState) { ws.zero = true }
+}
+
+// BlankBools makes Write write single-valued bool variables set to true as
+// blank values, that is by the variable name alone.
+func BlankBools() WriteOption {
+	return func(ws *writeState) { ws.blankBools = true }
+}
+
+type formatter func(srcp interface{}, t metadata) (string, error)
+
+var formatters = []formatter{
+	typeFormatter, textMarshalerFormatter, kindFormatter, printFormatter,
+}
+
+func typeFormatter(s interface{}, t metadata) (string, error) {
+	tp := reflect.TypeOf(s).Elem()
+	typeFormatters.RLock()
+	formatter, ok := typeFormatters.m[tp]
+	typeFormatters.RUnlock()
+	if !ok {
+		formatter, ok = typeFormatterFuncs[tp]
+	}
+	if !ok {
+		return "", errUnsupportedType
+	}
+	return formatter(s, t)
+}
+
+func textMarshalerFormatter(s interface{}, t metadata) (string, error) {
+	stm, ok := s.(textMarshaler)
+	if !ok {
+		return "", errUnsupportedType
+	}
+	b, err := stm.MarshalText()
+	if err != nil {
+		return "", err
+	}
+	return string(b), nil
+}
+
+func boolFormatter(s interface{}, t metadata) (string, error) {
+	return strconv.FormatBool(reflect.ValueOf(s).Elem().Bool()), nil
+}
+
+// intFormatter formats an integer in a base accepted when reading it: decimal
+// if allowed, otherwise hexadecimal or octal with prefix.
+func intFormatter(s interface{}, t metadata) (string, error) {
+	mode := intMode(t.intMode)
+	if mode == 0 {
+		mode = intModeDefault(reflect.TypeOf(s).Elem())
+	}
+	verb := "%d"
+	switch {
+	case mode&types.Dec != 0:
+	case mode&types.Hex != 0:
+		verb = "%#x"
+	default:
+		verb = "%#o"
+	}
+	if _, ok := s.(*big.Int); ok {
+		return fmt.Sprintf(verb, s), nil
+	}
+	return fmt.Sprintf(verb, reflect.ValueOf(s).Elem().Interface()), nil
+}
+
+func stringFormatter(s interface{}, t metadata) (string, error) {
+	return reflect.ValueOf(s).Elem().String(), nil
+}
+
+var kindFormatters = map[reflect.Kind]formatter{
+	reflect.String:  stringFormatter,
+	reflect.Bool:    boolFormatter,
+	reflect.Int:     intFormatter,
+	reflect.Int8:    intFormatter,
+	reflect.Int16:   intFormatter,
+	reflect.Int32:   intFormatter,
+	reflect.Int64:   intFormatter,
+	reflect.Uint:    intFormatter,
+	reflect.Uint8:   intFormatter,
+	reflect.Uint16:  intFormatter,
+	reflect.Uint32:  intFormatter,
+	reflect.Uint64:  intFormatter,
+	reflect.Uintptr: intFormatter,
+}
+
+var typeFormatterFuncs = map[reflect.Type]formatter{
+	reflect.TypeOf(big.Int{}): intFormatter,
+	reflect.TypeOf(big.Rat{}): ratFormatter,
+}
+
+func kindFormatter(s interface{}, t metadata) (string, error) {
+	formatter, ok := kindFormatters[reflect.TypeOf(s).Elem().Kind()]
+	if !ok {
+		return "", errUnsupportedType
+	}
+	return formatter(s, t)
+}
+
+// printFormatter formats types other than the above with their String method,
+// or else with the "%v" verb, the counterpart of reading with fmt.Sscanf.
+func printFormatter(s interface{}, t metadata) (string, error) {
+	if ss, ok := s.(fmt.Stringer); ok {
+		return ss.String(), nil
+	}
+	return fmt.Sprint(reflect.ValueOf(s).Elem().Interface()), nil
+}
+
+// formatters returns the formatters to use, starting with the type formatters
+// given as options.
+func (ws *writeState) formatters() []formatter {
+	if len(ws.typeFormatters) == 0 {
+		return formatters
+	}
+	tf := func(s interface{}, t metadata) (string, error) {
+		f, ok := ws.typeFormatters[reflect.TypeOf(s).Elem()]
+		if !ok {
+			return "", errUnsupportedType
+		}
+		return f(s, t)
+	}
+	return append([]formatter{tf}, formatters...)
+}
+
+// formatValue returns the value v formatted as it is read back, using the
+// first formatter in fs that supports its type. Pointers are dereferenced; v
+// must not be a nil pointer.
+func formatValue(v reflect.Value, t metadata, fs []formatter) (string, error) {
+	if v.Type().Name() == "" && v.Kind() == reflect.Ptr {
+		v = v.Elem()
+	}
+	if !v.CanAddr() {
+		vc := reflect.New(v.Type()).Elem()
+		vc.Set(v)
+		v = vc
+	}
+	s := v.Addr().Interface()
+	for _, f := range fs {
+		val, err := f(s, t)
+		if err != errUnsupportedType {
+			return val, err
+		}
+	}
+	return "", errUnsupportedType
+}
+
+// isZero reports whether v holds the zero value of its type.
+func isZero(v reflect.Value) bool {
+	return reflect.DeepEqual(reflect.Zero(v.Type()).Interface(), v.Interface())
+}
+
+// writeItem writes the variable name with the value v. Zero values are left
+// out of single-valued variables unless requested, or always with the
+// "omitempty" option, which also leaves out pointers to zero values. Nil
+// pointers are always left out.
+func (ws *writeState) writeItem(v reflect.Value, name string, t metadata, multi bool) error {
+	isDeref := v.Type().Name() == "" && v.Kind() == reflect.Ptr
+	if isDeref && v.IsNil() {
+		return nil
+	}
+	if !multi && (t.omitempty && isZero(reflect.Indirect(v)) ||
+		!isDeref && !ws.zero && isZero(v)) {
+		return nil
+	}
+	line := name
+	if vb := reflect.Indirect(v); ws.blankBools && !multi && vb.Kind() == reflect.Bool &&
+		vb.Bool() {
+		line += "\n"
+	} else {
+		val, err := formatValue(v, t, ws.formatters())
+		if err == nil {
+			val, err = ast.QuoteValue(val)
 		}
-		if _, err := w.Write([]byte(fmt.Sprintf("%s = %v\n", name, vi))); err != nil {
-			return err
+		if err != nil {
+			return fmt.Errorf("variable %q: %v", name, err)
 		}
+		line += " = " + val + "\n"
 	}
-	return nil
+	_, err := io.WriteString(ws.w, line)
+	return err
 }
 
-func writeInSection(vSect reflect.Value, w io.Writer) error {
+func (ws *writeState) writeInSection(vSect reflect.Value) error {
 	tp := vSect.Type()
 	if tp.Kind() == reflect.Ptr {
 		if vSect.IsNil() {
@@ -38,62 +227,87 @@ func writeInSection(vSect reflect.Value, w io.Writer) error {
 		vSect = vSect.Elem()
 		tp = vSect.Type()
 	}
-	for i, n := 0, vSect.NumField(); i < n; i++ {
-		vVar := vSect.Field(i)
-		if !vVar.IsValid() {
+	si := structInfoOf(tp)
+	for _, f := range si.fields {
+		vVar := fieldByIndex(vSect, f.index, nil)
+		if !vVar.IsValid() || si.lookup(f.name) != f {
 			continue
 		}
-		sf := tp.Field(i)
-		if sf.Anonymous {
-			if err := writeInSection(vVar, w); err != nil {
+		if !ast.IsName(f.name) {
+			return fmt.Errorf("invalid variable name %q", f.name)
+		}
+		if !isMulti(vVar) {
+			if err := ws.writeItem(vVar, f.name, f.meta, false); err != nil {
 				return err
 			}
 			continue
 		}
-		t := newMetadata(sf.Tag.Get("gcfg"), sf.Tag)
-		in := t.ident
-		if in == "-" {
-			continue
-		}
-		if in == "" {
-			in = strings.ToLower(sf.Name)
-		}
-		isMulti := vVar.Type().Name() == "" && vVar.Kind() == reflect.Slice
-		if !isMulti {
-			writeItem(vVar, in, w)
-		} else {
-			for i, n := 0, vVar.Len(); i < n; i++ {
-				writeItem(vVar.Index(i), in, w)
+		for i, n := 0, vVar.Len(); i < n; i++ {
+			if err := ws.writeItem(vVar.Index(i), f.name, f.meta, true); err != nil {
+				return err
 			}
 		}
 	}
 	return nil
 }
 
-func writeSection(vSect reflect.Value, name string, w io.Writer) error {
-	if _, err := w.Write([]byte("[")); err != nil {
+// writeSection writes the section name and subsection sub with the variables
+// in vSect. With the "omitempty" option, a section without variables to write
+// is left out.
+func (ws *writeState) writeSection(vSect reflect.Value, name, sub string, t metadata) error {
+	h, err := ast.Header(name, sub)
+	if err != nil {
 		return err
 	}
-	if _, err := w.Write([]byte(name)); err != nil {
+	w := ws.w
+	defer func() { ws.w = w }()
+	var buf bytes.Buffer
+	ws.w = &buf
+	if err := ws.writeInSection(vSect); err != nil {
 		return err
 	}
-	if _, err := w.Write([]byte("]\n")); err != nil {
-		return err
+	if buf.Len() == 0 && t.omitempty {
+		return nil
 	}
-	if err := writeInSection(vSect, w); err != nil {
-		return err
+	_, err = io.WriteString(w, h+"\n"+buf.String()+"\n")
+	return err
+}
+
+// subsections returns the names of the subsections in the map vSect of section
+// name, in the order they are written.
+func (ws *writeState) subsections(name string, vSect reflect.Value) []string {
+	var subs []string
+	for _, k := range vSect.MapKeys() {
+		subs = append(subs, k.String())
 	}
-	if _, err := w.Write([]byte("\n")); err != nil {
-		return err
+	sort.Strings(subs)
+	if ws.order == nil {
+		return subs
 	}
-	return nil
+	left := map[string]bool{}
+	for _, sub := range subs {
+		left[sub] = true
+	}
+	var res []string
+	for _, sub := range ws.order(name, append([]string(nil), subs...)) {
+		if left[sub] {
+			res = append(res, sub)
+			delete(left, sub)
+		}
+	}
+	for _, sub := range subs {
+		if left[sub] {
+			res = append(res, sub)
+		}
+	}
+	return res
 }
 
-func write(vc reflect.Value, w io.Writer) error {
-	vt := vc.Type()
-	for i, n := 0, vc.NumField(); i < n; i++ {
-		vSect := vc.Field(i)
-		if !vSect.IsValid() {
+func (ws *writeState) write(vc reflect.Value) error {
+	si := structInfoOf(vc.Type())
+	for _, f := range si.fields {
+		vSect := fieldByIndex(vc, f.index, nil)
+		if !vSect.IsValid() || si.lookup(f.name) != f {
 			continue
 		}
 		isMap := false
@@ -115,27 +329,16 @@ func write(vc reflect.Value, w io.Writer) error {
 		} else if vSect.Kind() != reflect.Struct {
 			continue
 		}
-		sf := vt.Field(i)
-		if sf.Anonymous {
-			if err := write(vSect, w); err != nil {
-				return err
-			}
-			continue
-		}
-		t := newMetadata(sf.Tag.Get("gcfg"), sf.Tag)
-		name := t.ident
-		if name == "" {
-			name = strings.ToLower(sf.Name)
-		}
+		name := f.name
 		if isMap {
-			for _, k := range vSect.MapKeys() {
-				n := fmt.Sprintf("%s \"%s\"", name, k)
-				if err := writeSection(vSect.MapIndex(k).Elem(), n, w); err != nil {
+			for _, sub := range ws.subsections(name, vSect) {
+				pv := vSect.MapIndex(reflect.ValueOf(sub))
+				if err := ws.writeSection(pv, name, sub, f.meta); err != nil {
 					return err
 				}
 			}
 		} else {
-			if err := writeSection(vSect, name, w); err != nil {
+			if err := ws.writeSection(vSect, name, "", f.meta); err != nil {
 				return err
 			}
 		}
@@ -143,21 +346,86 @@ func write(vc reflect.Value, w io.Writer) error {
 	return nil
 }
 
-// Write writes config in gcfg formatted data.
-func Write(config interface{}, w io.Writer) error {
+// Write writes config in gcfg formatted data. Sections and variables are
+// named and resolved the same way as when reading, including the fields of
+// embedded structs; the first error in the type of config is returned. Fields
+// that reading does not set, such as unexported fields, fields tagged "-" and
+// fields whose name is taken by the tag of another field, are not written.
+//
+// Values and subsection names are quoted and escaped as needed to read back
+// unchanged. Values and subsection names that cannot be represented in gcfg
+// data, such as those with carriage returns or invalid UTF-8, are an error.
+//
+// Sections are written in the order of the fields of config, and subsections
+// in order of their names, unless ordered otherwise with OrderSubsections.
+//
+// Values are formatted the way they are parsed when reading: with a
+// registered TypeFormatter, with the MarshalText method for types
+// implementing encoding.TextMarshaler, as bool, string or integer, the latter
+// in a base allowed by the ",int=mode" tag option, or else with the String
+// method or the "%v" verb. Pointers are written as the values they point to,
+// and nil pointers are left out. Single-valued variables holding the zero
+// value of their type are left out unless EmitZeroValues is given; each value
+// of a multi-valued variable is written. With the struct tag option
+// ",omitempty", a variable is left out if it holds the zero value, or points
+// to one, and a section or subsection is left out if none of its variables are
+// written.
+func Write(config interface{}, w io.Writer, opts ...WriteOption) error {
 	vpc := reflect.ValueOf(config)
 	if vpc.Kind() != reflect.Ptr || vpc.Elem().Kind() != reflect.Struct {
 		panic(fmt.Errorf("config must be a pointer to a struct"))
 	}
 	vc := vpc.Elem()
-	return write(vc, w)
+	if errs := typeErrors(vc.Type()); len(errs) > 0 {
+		return errs[0]
+	}
+	ws := &writeState{w: w}
+	for _, opt := range opts {
+		opt(ws)
+	}
+	return ws.write(vc)
 }
 
+// A TypeFormatter formats a value of a given type as written in gcfg data,
+// before quoting.
 type TypeFormatter func(interface{}) string
 
-var typeFormatters = map[reflect.Type]TypeFormatter{}
+// typeFormatters holds the registered formatters.
+var typeFormatters = struct {
+	sync.RWMutex
+	m map[reflect.Type]formatter
+}{m: map[reflect.Type]formatter{}}
 
 func RegisterTypeFormatter(tgtType reflect.Type, typeFormatter TypeFormatter) error {
-	typeFormatters[tgtType] = typeFormatter
+	registerFormatter(tgtType, typeFormatterFunc(typeFormatter))
 	return nil
 }
+
+func registerFormatter(tgtType reflect.Type, f formatter) {
+	typeFormatters.Lock()
+	typeFormatters.m[tgtType] = f
+	typeFormatters.Unlock()
+}
+
+// typeFormatterFunc returns the formatter using typeFormatter.
+func typeFormatterFunc(typeFormatter TypeFormatter) formatter {
+	return func(s interface{}, t metadata) (string, error) {
+		return typeFormatter(reflect.ValueOf(s).Elem().Interface()), nil
+	}
+}
+
+// UseTypeFormatter makes the write format values of type tgtType with
+// typeFormatter, in preference to a formatter registered with
+// RegisterTypeFormatter and without affecting other writes.
+func UseTypeFormatter(tgtType reflect.Type, typeFormatter TypeFormatter) WriteOption {
+	return useFormatter(tgtType, typeFormatterFunc(typeFormatter))
+}
+
+func useFormatter(tgtType reflect.Type, f formatter) WriteOption {
+	return func(ws *writeState) {
+		if ws.typeFormatters == nil {
+			ws.typeFormatters = map[reflect.Type]formatter{}
+		}
+		ws.typeFormatters[tgtType] = f
+	}
+}
@@ -0,0 +1,266 @@
+package gcfg
+
+import (
+	"bytes"
+	"math/big"
+	"math/rand"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+	"testing/quick"
+	"unicode/utf8"
+)
+
+type cWriteQuote struct {
+	Section struct {
+		Value string
+		Multi []string
+	}
+	Sub map[string]*cWriteQuoteS1
+}
+type cWriteQuoteS1 struct {
+	Value string
+}
+
+// quoteAlphabet holds the characters significant to the gcfg syntax, and some
+// that cannot be written.
+var quoteAlphabet = []string{"a", "Z", "0", "é", "世", " ", "\t", "\n", "\r", "\x00",
+	"\xff", ";", "#", "=", "\"", "\\", "[", "]", "\\n", "\\\n"}
+
+func randQuoteString(r *rand.Rand) string {
+	var b bytes.Buffer
+	for i, n := 0, r.Intn(8); i < n; i++ {
+		b.WriteString(quoteAlphabet[r.Intn(len(quoteAlphabet))])
+	}
+	return b.String()
+}
+
+func TestWriteQuotingRoundTrip(t *testing.T) {
+	writable := func(s string, sub bool) bool {
+		bad := "\r\x00"
+		if sub {
+			bad += "\n"
+		}
+		return utf8.ValidString(s) && !strings.ContainsAny(s, bad)
+	}
+	f := func(val, multi, sub string) bool {
+		cfg := &cWriteQuote{}
+		cfg.Section.Value = val
+		if multi != "" {
+			cfg.Section.Multi = []string{multi, val + "."}
+		}
+		// subsections without variables are not read back
+		cfg.Sub = map[string]*cWriteQuoteS1{sub: {Value: val + "."}}
+		var buf bytes.Buffer
+		err := Write(cfg, &buf)
+		if !writable(val, false) || !writable(multi, false) || !writable(sub, true) {
+			return err != nil
+		}
+		if err != nil {
+			t.Logf("%q %q %q: %v", val, multi, sub, err)
+			return false
+		}
+		res := &cWriteQuote{}
+		if err := ReadInto(res, &buf); err != nil {
+			t.Logf("%q %q %q: %v\n%s", val, multi, sub, err, buf.String())
+			return false
+		}
+		return reflect.DeepEqual(res, cfg)
+	}
+	cfg := &quick.Config{
+		MaxCount: 2000,
+		Values: func(args []reflect.Value, r *rand.Rand) {
+			for i := range args {
+				args[i] = reflect.ValueOf(randQuoteString(r))
+			}
+		},
+	}
+	if err := quick.Check(f, cfg); err != nil {
+		t.Error(err)
+	}
+	if err := quick.Check(f, &quick.Config{MaxCount: 500}); err != nil {
+		t.Error(err)
+	}
+}
+
+type cWriteOrder struct {
+	Sub map[string]*cWriteQuoteS1
+}
+
+var writeOrderTests = []struct {
+	in    string
+	order func(o *Order) WriteOption
+	exp   []string
+}{
+	{"[sub \"b\"]\nvalue=1\n[sub \"a\"]\nvalue=2\n[sub]\nvalue=3\n",
+		nil,
+		[]string{`[sub]`, `[sub "a"]`, `[sub "b"]`}},
+	{"[sub \"b\"]\nvalue=1\n[sub \"a\"]\nvalue=2\n[sub]\nvalue=3\n[sub \"b\"]\nvalue=4\n",
+		func(o *Order) WriteOption { return OrderSubsections(o.Subsections) },
+		[]string{`[sub "b"]`, `[sub "a"]`, `[sub]`}},
+	{"[sub \"b\"]\nvalue=1\n[sub \"a\"]\nvalue=2\n[sub]\nvalue=3\n",
+		func(*Order) WriteOption {
+			return OrderSubsections(func(sect string, subs []string) []string {
+				if sect != "sub" {
+					return nil
+				}
+				// reversed; "a" left out
+				return []string{subs[2], subs[0]}
+			})
+		},
+		[]string{`[sub "b"]`, `[sub]`, `[sub "a"]`}},
+}
+
+func TestWriteOrder(t *testing.T) {
+	for i, tt := range writeOrderTests {
+		var o Order
+		cfg := &cWriteOrder{}
+		if err := ReadStringInto(cfg, tt.in, RecordOrder(&o)); err != nil {
+			t.Errorf("%d: read: %v", i, err)
+			continue
+		}
+		for n := 0; n < 5; n++ {
+			var opts []WriteOption
+			if tt.order != nil {
+				opts = append(opts, tt.order(&o))
+			}
+			var buf bytes.Buffer
+			if err := Write(cfg, &buf, opts...); err != nil {
+				t.Errorf("%d: write: %v", i, err)
+				break
+			}
+			var hs []string
+			for _, l := range strings.Split(buf.String(), "\n") {
+				if strings.HasPrefix(l, "[") {
+					hs = append(hs, l)
+				}
+			}
+			if !reflect.DeepEqual(hs, tt.exp) {
+				t.Errorf("%d: got %q, want %q", i, hs, tt.exp)
+				break
+			}
+		}
+	}
+}
+
+type cWriteTextS1 struct{ s string }
+
+func (s cWriteTextS1) MarshalText() ([]byte, error) { return []byte("<" + s.s + ">"), nil }
+func (s *cWriteTextS1) UnmarshalText(b []byte) error {
+	s.s = strings.Trim(string(b), "<>")
+	return nil
+}
+
+type cWriteValuesS1 struct {
+	Bool    bool
+	BoolP   *bool
+	Int     int
+	IntP    *int
+	Hex     int    `gcfg:",int=h"`
+	Oct     uint16 `gcfg:",int=o"`
+	HexOct  int    `gcfg:",int=ho"`
+	Big     big.Int
+	BigP    *big.Int
+	BigHex  *big.Int `gcfg:",int=h"`
+	String  string
+	StringP *string
+	Text    cWriteTextS1
+	Mode    os.FileMode
+	Multi   []bool
+	Ints    []int `gcfg:",int=h"`
+}
+
+type cWriteValues struct {
+	Section cWriteValuesS1
+}
+
+func TestWriteValues(t *testing.T) {
+	f, zero, big42 := false, 0, big.NewInt(-42)
+	s := ""
+	cfg := &cWriteValues{cWriteValuesS1{
+		Bool: true, BoolP: &f, IntP: &zero, Hex: -255, Oct: 0755, HexOct: 8,
+		BigP: big42, BigHex: big.NewInt(255), StringP: &s,
+		Text: cWriteTextS1{"t"}, Mode: 0644, Multi: []bool{true, false},
+		Ints: []int{0, 16},
+	}}
+	cfg.Section.Big.SetString("123456789012345678901234567890", 10)
+	for _, tt := range []struct {
+		opts []WriteOption
+		exp  string
+	}{
+		{nil, "[section]\nbool = true\nboolp = false\nintp = 0\nhex = -0xff\n" +
+			"oct = 0755\nhexoct = 0x8\nbig = 123456789012345678901234567890\n" +
+			"bigp = -42\nbighex = 0xff\nstringp = \"\"\ntext = <t>\nmode = 420\n" +
+			"multi = true\nmulti = false\nints = 0x0\nints = 0x10\n\n"},
+		{[]WriteOption{BlankBools(), EmitZeroValues()}, "[section]\nbool\nboolp = false\n" +
+			"int = 0\nintp = 0\nhex = -0xff\noct = 0755\nhexoct = 0x8\n" +
+			"big = 123456789012345678901234567890\nbigp = -42\nbighex = 0xff\n" +
+			"string = \"\"\nstringp = \"\"\ntext = <t>\nmode = 420\n" +
+			"multi = true\nmulti = false\nints = 0x0\nints = 0x10\n\n"},
+	} {
+		var buf bytes.Buffer
+		if err := Write(cfg, &buf, tt.opts...); err != nil {
+			t.Errorf("%d: write: %v", len(tt.opts), err)
+			continue
+		}
+		if buf.String() != tt.exp {
+			t.Errorf("%d: got\n%s\nwant\n%s", len(tt.opts), buf.String(), tt.exp)
+			continue
+		}
+		res := &cWriteValues{}
+		if err := ReadInto(res, &buf); err != nil {
+			t.Errorf("%d: read: %v", len(tt.opts), err)
+			continue
+		}
+		if !reflect.DeepEqual(res, cfg) {
+			t.Errorf("%d: read back %+v, want %+v", len(tt.opts), res, cfg)
+		}
+	}
+}
+
+type cWriteNamesS1 struct {
+	Foo_Bar string
+	X世界     string
+	Tagged  string `gcfg:"other-name"`
+	Skipped string `gcfg:"-"`
+	hidden  string
+	Name    string
+	Alias   string `gcfg:"name"`
+	Empty   int    `gcfg:",omitempty"`
+	EmptyP  *int   `gcfg:",omitempty"`
+}
+
+type cWriteNames struct {
+	Sect_Name cWriteNamesS1
+	Skipped   cWriteNamesS1 `gcfg:"-"`
+	hidden    cWriteNamesS1
+	Empty     *cWriteNamesS1            `gcfg:",omitempty"`
+	Subs      map[string]*cWriteNamesS1 `gcfg:",omitempty"`
+}
+
+func TestWriteNames(t *testing.T) {
+	zero := 0
+	s := cWriteNamesS1{Foo_Bar: "a", X世界: "b", Tagged: "c", Skipped: "d",
+		hidden: "e", Name: "f", Alias: "g", EmptyP: &zero}
+	cfg := &cWriteNames{Sect_Name: s, Skipped: s, hidden: s,
+		Empty: &cWriteNamesS1{}, Subs: map[string]*cWriteNamesS1{"x": {}, "y": {Alias: "h"}}}
+	exp := "[sect-name]\nfoo-bar = a\n世界 = b\nother-name = c\nname = g\n\n" +
+		"[subs \"y\"]\nname = h\n\n"
+	var buf bytes.Buffer
+	if err := Write(cfg, &buf); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	if buf.String() != exp {
+		t.Fatalf("got\n%s\nwant\n%s", buf.String(), exp)
+	}
+	res := &cWriteNames{}
+	if err := ReadInto(res, &buf); err != nil {
+		t.Fatalf("read: %v", err)
+	}
+	s = cWriteNamesS1{Foo_Bar: "a", X世界: "b", Tagged: "c", Alias: "g"}
+	want := &cWriteNames{Sect_Name: s, Subs: map[string]*cWriteNamesS1{"y": {Alias: "h"}}}
+	if !reflect.DeepEqual(res, want) {
+		t.Errorf("read back %+v, want %+v", res, want)
+	}
+}